@@ -0,0 +1,49 @@
+package api
+
+import "context"
+
+// PiotAPI is set of PIOT server operations used by commands, it is
+// implemented by Client and could be replaced by fake implementation
+// (see package apitest)
+type PiotAPI interface {
+	LoginContext(ctx context.Context) error
+	LoginWithCredentialsContext(ctx context.Context) error
+	Logout() error
+
+	// things
+	GetThingsContext(ctx context.Context, all bool, filter ThingFilterFunctionType) ([]Thing, error)
+	GetOrgThingsContext(ctx context.Context, orgId string, filter ThingFilterFunctionType) ([]Thing, error)
+	GetThingParentsContext(ctx context.Context, all bool) (map[string]string, error)
+	GetOrgThingParentsContext(ctx context.Context, orgId string) (map[string]string, error)
+	GetThingContext(ctx context.Context, id string) (*Thing, error)
+	CreateThingContext(ctx context.Context, name, thing_type string, attrs *ThingAttributes) (*Thing, error)
+	UpdateThingContext(ctx context.Context, id string, attrs *ThingAttributes) (*Thing, error)
+	DeleteThingContext(ctx context.Context, id string) error
+
+	// organizations
+	GetOrgsContext(ctx context.Context, filter OrgFilterFunctionType) ([]Org, error)
+	GetOrgContext(ctx context.Context, id string) (*Org, error)
+	GetOrgByNameContext(ctx context.Context, name string) (*Org, error)
+	SetCurrentOrgContext(ctx context.Context, name string) error
+	CreateOrgContext(ctx context.Context, attrs *OrgAttributes) (*Org, error)
+	UpdateOrgContext(ctx context.Context, id string, attrs *OrgAttributes) (*Org, error)
+	DeleteOrgContext(ctx context.Context, id string) error
+	GetOrgMembersContext(ctx context.Context, orgId string) ([]User, error)
+	AddOrgMemberContext(ctx context.Context, orgId, userId string) error
+	RemoveOrgMemberContext(ctx context.Context, orgId, userId string) error
+
+	// users
+	GetUsersContext(ctx context.Context, filter UserFilterFunctionType) ([]User, error)
+	GetUserContext(ctx context.Context, id string) (*User, error)
+	GetUserByEmailContext(ctx context.Context, email string) (*User, error)
+	CreateUserContext(ctx context.Context, attrs *UserAttributes) (*User, error)
+	UpdateUserContext(ctx context.Context, id string, attrs *UserAttributes) (*User, error)
+	DeleteUserContext(ctx context.Context, id string) error
+
+	// profile of logged user
+	GetUserProfileContext(ctx context.Context) (UserProfile, error)
+	UpdateUserProfileContext(ctx context.Context, attrs *UserProfileAttributes) (UserProfile, error)
+	ChangePasswordContext(ctx context.Context, current, password string) error
+}
+
+var _ PiotAPI = (*Client)(nil)
@@ -0,0 +1,122 @@
+package api
+
+import "context"
+
+// Variants of API methods using background context, they could be used when
+// cancellation of requests is not needed.
+
+func (c *Client) Login() error {
+	return c.LoginContext(context.Background())
+}
+
+func (c *Client) LoginWithCredentials() error {
+	return c.LoginWithCredentialsContext(context.Background())
+}
+
+func (c *Client) GetThings(all bool, filter ThingFilterFunctionType) ([]Thing, error) {
+	return c.GetThingsContext(context.Background(), all, filter)
+}
+
+func (c *Client) GetOrgThings(orgId string, filter ThingFilterFunctionType) ([]Thing, error) {
+	return c.GetOrgThingsContext(context.Background(), orgId, filter)
+}
+
+func (c *Client) GetThingParents(all bool) (map[string]string, error) {
+	return c.GetThingParentsContext(context.Background(), all)
+}
+
+func (c *Client) GetOrgThingParents(orgId string) (map[string]string, error) {
+	return c.GetOrgThingParentsContext(context.Background(), orgId)
+}
+
+func (c *Client) GetOrgs(filter OrgFilterFunctionType) ([]Org, error) {
+	return c.GetOrgsContext(context.Background(), filter)
+}
+
+func (c *Client) GetOrgByName(name string) (*Org, error) {
+	return c.GetOrgByNameContext(context.Background(), name)
+}
+
+func (c *Client) SetCurrentOrg(name string) error {
+	return c.SetCurrentOrgContext(context.Background(), name)
+}
+
+func (c *Client) CreateThing(name, thing_type string, attrs *ThingAttributes) (*Thing, error) {
+	return c.CreateThingContext(context.Background(), name, thing_type, attrs)
+}
+
+func (c *Client) UpdateThing(id string, attrs *ThingAttributes) (*Thing, error) {
+	return c.UpdateThingContext(context.Background(), id, attrs)
+}
+
+func (c *Client) GetThing(id string) (*Thing, error) {
+	return c.GetThingContext(context.Background(), id)
+}
+
+func (c *Client) DeleteThing(id string) error {
+	return c.DeleteThingContext(context.Background(), id)
+}
+
+func (c *Client) GetUserProfile() (UserProfile, error) {
+	return c.GetUserProfileContext(context.Background())
+}
+
+func (c *Client) GetOrg(id string) (*Org, error) {
+	return c.GetOrgContext(context.Background(), id)
+}
+
+func (c *Client) CreateOrg(attrs *OrgAttributes) (*Org, error) {
+	return c.CreateOrgContext(context.Background(), attrs)
+}
+
+func (c *Client) UpdateOrg(id string, attrs *OrgAttributes) (*Org, error) {
+	return c.UpdateOrgContext(context.Background(), id, attrs)
+}
+
+func (c *Client) DeleteOrg(id string) error {
+	return c.DeleteOrgContext(context.Background(), id)
+}
+
+func (c *Client) GetOrgMembers(orgId string) ([]User, error) {
+	return c.GetOrgMembersContext(context.Background(), orgId)
+}
+
+func (c *Client) AddOrgMember(orgId, userId string) error {
+	return c.AddOrgMemberContext(context.Background(), orgId, userId)
+}
+
+func (c *Client) RemoveOrgMember(orgId, userId string) error {
+	return c.RemoveOrgMemberContext(context.Background(), orgId, userId)
+}
+
+func (c *Client) GetUsers(filter UserFilterFunctionType) ([]User, error) {
+	return c.GetUsersContext(context.Background(), filter)
+}
+
+func (c *Client) GetUser(id string) (*User, error) {
+	return c.GetUserContext(context.Background(), id)
+}
+
+func (c *Client) GetUserByEmail(email string) (*User, error) {
+	return c.GetUserByEmailContext(context.Background(), email)
+}
+
+func (c *Client) CreateUser(attrs *UserAttributes) (*User, error) {
+	return c.CreateUserContext(context.Background(), attrs)
+}
+
+func (c *Client) UpdateUser(id string, attrs *UserAttributes) (*User, error) {
+	return c.UpdateUserContext(context.Background(), id, attrs)
+}
+
+func (c *Client) DeleteUser(id string) error {
+	return c.DeleteUserContext(context.Background(), id)
+}
+
+func (c *Client) UpdateUserProfile(attrs *UserProfileAttributes) (UserProfile, error) {
+	return c.UpdateUserProfileContext(context.Background(), attrs)
+}
+
+func (c *Client) ChangePassword(current, password string) error {
+	return c.ChangePasswordContext(context.Background(), current, password)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,20 +14,61 @@ import (
 )
 
 type Client struct {
-	user     string
-	password string
-	url      string
-	log      *logging.Logger
-	token    string
+	user       string
+	password   string
+	url        string
+	log        *logging.Logger
+	token      string
+	tokenCache *TokenCache
+	httpClient *http.Client
+	httpErr    error
+	retries    int
+}
+
+// ClientConfig holds connection settings of PIOT API client
+type ClientConfig struct {
+	Url      string
+	User     string
+	Password string
+	HTTP     HTTPConfig
+
+	// tokens are not cached if set (e.g. for temporary servers)
+	NoTokenCache bool
+}
+
+// ClientConfigFromViper returns client settings given by config file, env
+// variables and flags
+func ClientConfigFromViper() ClientConfig {
+	return ClientConfig{
+		Url:      viper.GetString("piot.url"),
+		User:     viper.GetString("piot.user"),
+		Password: viper.GetString("piot.password"),
+		HTTP:     HTTPConfigFromViper(),
+	}
 }
 
 func NewClient(logger *logging.Logger) *Client {
+	return NewClientWithConfig(logger, ClientConfigFromViper())
+}
+
+func NewClientWithConfig(logger *logging.Logger, conf ClientConfig) *Client {
 	client := &Client{}
 	client.log = logger
-	client.user = viper.GetString("piot.user")
-	client.password = viper.GetString("piot.password")
-	client.url = viper.GetString("piot.url")
+	client.user = conf.User
+	client.password = conf.Password
+	client.url = conf.Url
 	client.token = ""
+	// invalid connection settings are reported by first request
+	client.httpClient, client.httpErr = NewHTTPClient(conf.HTTP)
+	client.retries = conf.HTTP.Retries
+
+	if !conf.NoTokenCache {
+		tokenCache, err := NewTokenCache()
+		if err != nil {
+			client.log.Warningf("Token cache not available: %v", err)
+		}
+		client.tokenCache = tokenCache
+	}
 
 	client.log.Debug("New instance of api client created:")
 	client.log.Debugf("  user: %s", client.user)
@@ -35,40 +77,57 @@ func NewClient(logger *logging.Logger) *Client {
 	return client
 }
 
-func (c *Client) execute(method string, path string, body *[]byte) (*http.Response, error) {
-	var bodyIoReader io.Reader
+// execute sends request, transient failures are retried only if retry is
+// set (request is idempotent)
+func (c *Client) execute(ctx context.Context, method string, path string, body *[]byte, retry bool) (*http.Response, error) {
 
 	var url string
 	url = fmt.Sprintf("%s/%s", c.url, path)
 
+	if c.httpErr != nil {
+		return nil, c.httpErr
+	}
+
 	c.log.Debugf("------%s Request to: %s", method, url)
 	if body != nil {
 		c.log.Debugf("Request body: %s", string(*body))
-		bodyIoReader = bytes.NewBuffer(*body)
 	}
 
-	req, err := http.NewRequest(method, url, bodyIoReader)
-	if err != nil {
-		return nil, err
-	}
+	newRequest := func() (*http.Request, error) {
+		var bodyIoReader io.Reader
+		if body != nil {
+			bodyIoReader = bytes.NewBuffer(*body)
+		}
 
-	req.Header.Add("Accept", "application/json")
+		req, err := http.NewRequestWithContext(ctx, method, url, bodyIoReader)
+		if err != nil {
+			return nil, err
+		}
 
-	// Add this header only for requests with body
-	if body != nil {
-		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("Accept", "application/json")
+
+		// Add this header only for requests with body
+		if body != nil {
+			req.Header.Add("Content-Type", "application/json")
+		}
+
+		if c.token == "" {
+			c.log.Debug("Setting basic authorization (header)")
+			req.SetBasicAuth(c.user, c.password)
+		} else {
+			c.log.Debugf("Setting bearer authorization (reusing token %s)", c.token)
+			req.Header.Add("Authorization", "Bearer "+c.token)
+		}
+
+		return req, nil
 	}
 
-	if c.token == "" {
-		c.log.Debug("Setting basic authorization (header)")
-		req.SetBasicAuth(c.user, c.password)
-	} else {
-		c.log.Debugf("Setting bearer authorization (reusing token %s)", c.token)
-		req.Header.Add("Authorization", "Bearer "+c.token)
+	retries := 0
+	if retry {
+		retries = c.retries
 	}
-	httpClient := &http.Client{}
 
-	resp, err := httpClient.Do(req)
+	resp, err := DoWithRetry(ctx, c.httpClient, retries, c.log, newRequest)
 	if err != nil {
 		return resp, err
 	}
@@ -95,54 +154,74 @@ func (c *Client) successfulResponse(resp *http.Response) (*http.Response, error)
 	return resp, nil
 }
 
-func (c *Client) get(path string) (*http.Response, error) {
-	return c.execute("GET", path, nil)
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	return c.execute(ctx, "GET", path, nil, true)
 }
 
-func (c *Client) getSuccessful(path string) (*http.Response, error) {
-	resp, err := c.execute("GET", path, nil)
+// executeSuccessful sends request and checks response status. If token was
+// rejected by the server (e.g. cached token expired), client logs in again
+// and the request is repeated once.
+func (c *Client) executeSuccessful(ctx context.Context, method string, path string, body *[]byte, retry bool) (*http.Response, error) {
+	resp, err := c.execute(ctx, method, path, body, retry)
 	if err != nil {
 		return resp, err
 	}
-	return c.successfulResponse(resp)
-}
 
-func (c *Client) postSuccessful(path string, body *[]byte) (*http.Response, error) {
-	resp, err := c.execute("POST", path, body)
-	if err != nil {
+	resp, err = c.successfulResponse(resp)
+	if err == nil || !isApiAuthError(err) || c.token == "" {
 		return resp, err
 	}
-	return c.successfulResponse(resp)
-}
 
-func (c *Client) deleteSuccessful(path string) (*http.Response, error) {
-	resp, err := c.execute("DELETE", path, nil)
+	c.log.Info("Token rejected by server, logging in again")
+	resp.Body.Close()
+
+	err = c.LoginWithCredentialsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err = c.execute(ctx, method, path, body, retry)
 	if err != nil {
 		return resp, err
 	}
+
 	return c.successfulResponse(resp)
 }
 
-func (c *Client) gqlQuerySuccessful(gql string) (*http.Response, error) {
+func (c *Client) getSuccessful(ctx context.Context, path string) (*http.Response, error) {
+	return c.executeSuccessful(ctx, "GET", path, nil, true)
+}
 
-	jsonData := map[string]string{
-		"query": fmt.Sprintf(`%s`, gql),
-	}
+func (c *Client) postSuccessful(ctx context.Context, path string, body *[]byte, retry bool) (*http.Response, error) {
+	return c.executeSuccessful(ctx, "POST", path, body, retry)
+}
+
+func (c *Client) deleteSuccessful(ctx context.Context, path string) (*http.Response, error) {
+	return c.executeSuccessful(ctx, "DELETE", path, nil, false)
+}
+
+// gqlQuerySuccessful sends GraphQL query (or mutation) together with its
+// variables, values must never be formatted into query itself
+func (c *Client) gqlQuerySuccessful(ctx context.Context, gql string, variables map[string]interface{}) (*http.Response, error) {
+
+	jsonData := struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables,omitempty"`
+	}{gql, variables}
 
 	bodyBytes, err := json.Marshal(jsonData)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.postSuccessful("query", &bodyBytes)
+	// mutations are never retried, server could apply them twice
+	resp, err := c.postSuccessful(ctx, "query", &bodyBytes, IsIdempotentGql(gql))
 	if err != nil {
 		return nil, err
 	}
 
 	// check gql error inside successfull response
-	var data struct {
-		Errors []GqlError `json:"errors"`
-	}
+	var data GqlResponse
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -155,25 +234,7 @@ func (c *Client) gqlQuerySuccessful(gql string) (*http.Response, error) {
 	}
 
 	if len(data.Errors) > 0 {
-
-		var errorMessage string
-
-		for i := 0; i < len(data.Errors); i++ {
-
-			if i > 0 {
-				errorMessage += "\n"
-			}
-
-			errorMessage += fmt.Sprintf("GraphQL error: msg='%s'", data.Errors[i].Message)
-			for j := 0; j < len(data.Errors[i].Locations); j++ {
-				errorMessage += fmt.Sprintf(" line=%d, col=%d",
-					data.Errors[i].Locations[j].Line,
-					data.Errors[i].Locations[j].Column,
-				)
-			}
-		}
-
-		return resp, fmt.Errorf("%s", errorMessage)
+		return resp, data.Errors
 	}
 
 	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
@@ -181,12 +242,36 @@ func (c *Client) gqlQuerySuccessful(gql string) (*http.Response, error) {
 	return resp, nil
 }
 
-func (c *Client) Login() error {
+// LoginContext reuses token of current session or token cached by previous
+// invocation, credentials are used only if no token is available
+func (c *Client) LoginContext(ctx context.Context) error {
 
 	if c.token != "" {
 		c.log.Debug("Reusing existing token")
+		return nil
 	}
 
+	if c.tokenCache != nil {
+		token, err := c.tokenCache.Get(c.url, c.user)
+		if err != nil {
+			c.log.Warningf("Failed to read token cache: %v", err)
+		} else if token != "" {
+			c.log.Debugf("Reusing cached token (%s)", c.tokenCache.Path())
+			c.token = token
+			return nil
+		}
+	}
+
+	return c.LoginWithCredentialsContext(ctx)
+}
+
+// LoginWithCredentialsContext logs in with user and password and stores obtained
+// token to the token cache
+func (c *Client) LoginWithCredentialsContext(ctx context.Context) error {
+
+	// credentials are sent only if there is no token
+	c.token = ""
+
 	c.log.Infof("Logging as: %s", c.user)
 
 	var loginRequest struct {
@@ -204,7 +289,7 @@ func (c *Client) Login() error {
 		return err
 	}
 
-	resp, err := c.postSuccessful("login", &bodyBytes)
+	resp, err := c.postSuccessful(ctx, "login", &bodyBytes, true)
 	if err != nil {
 		return err
 	}
@@ -221,30 +306,82 @@ func (c *Client) Login() error {
 
 	c.token = result.Token
 
+	if c.tokenCache != nil {
+		err = c.tokenCache.Set(c.url, c.user, c.token)
+		if err != nil {
+			c.log.Warningf("Failed to store token to cache: %v", err)
+		}
+	}
+
 	return nil
 }
 
+// Logout forgets token of current session and removes it from the token cache
+func (c *Client) Logout() error {
+
+	c.token = ""
+
+	if c.tokenCache == nil {
+		return nil
+	}
+
+	return c.tokenCache.Delete(c.url, c.user)
+}
+
+// list of thing fields fetched from the server
+const gqlThingFields = "id, name, type, alias, enabled, last_seen, last_seen_interval, store_influxdb, store_mysqldb, sensor {value, class, unit}"
+
 type ThingFilterFunctionType = func(s *Thing) bool
 
-func (c *Client) GetThings(all bool, filter ThingFilterFunctionType) ([]Thing, error) {
+func (c *Client) GetThingsContext(ctx context.Context, all bool, filter ThingFilterFunctionType) ([]Thing, error) {
 
-	var result []Thing
+	gql := fmt.Sprintf(`
+		query ($all: Boolean) {
+			things (all: $all) {
+				%s
+			}
+		}
+		`, gqlThingFields)
 
-	all_str := "false"
-	if all {
-		all_str = "true"
+	things, err := c.queryThings(ctx, gql, map[string]interface{}{"all": all})
+	if err != nil {
+		return nil, err
 	}
 
+	return filterThings(things, filter), nil
+}
+
+// GetOrgThingsContext returns things of given organization. Things are
+// selected by server, so they could be fetched by members of organization
+// which is not active in their profile (all things are visible to admins only).
+func (c *Client) GetOrgThingsContext(ctx context.Context, orgId string, filter ThingFilterFunctionType) ([]Thing, error) {
+
 	gql := fmt.Sprintf(`
-		{
-			things (all: %s) {
-				id, name, type, alias, enabled, last_seen, last_seen_interval, store_influxdb, store_mysqldb, sensor {value, class, unit}
+		query ($org_id: ID!) {
+			things (org_id: $org_id) {
+				%s
 			}
 		}
-		`, all_str)
-	resp, err := c.gqlQuerySuccessful(gql)
+		`, gqlThingFields)
+
+	things, err := c.queryThings(ctx, gql, map[string]interface{}{"org_id": orgId})
 	if err != nil {
-		return result, err
+		return nil, err
+	}
+
+	// organization is known, there is no need to fetch it from server
+	for i := range things {
+		things[i].OrgId = orgId
+	}
+
+	return filterThings(things, filter), nil
+}
+
+func (c *Client) queryThings(ctx context.Context, gql string, variables map[string]interface{}) ([]Thing, error) {
+
+	resp, err := c.gqlQuerySuccessful(ctx, gql, variables)
+	if err != nil {
+		return nil, err
 	}
 
 	var data struct {
@@ -253,37 +390,95 @@ func (c *Client) GetThings(all bool, filter ThingFilterFunctionType) ([]Thing, e
 		}
 	}
 
-	// var gqlResponse interface{}
 	err = json.NewDecoder(resp.Body).Decode(&data)
 	if err != nil {
 		c.log.Error(err)
 	}
 
-	result = data.Data.Things
+	return data.Data.Things, nil
+}
 
-	// apply filtering if filter function was provided
-	if filter != nil {
-		var filteredResult []Thing
-		for _, thing := range data.Data.Things {
-			if filter(&thing) {
-				filteredResult = append(filteredResult, thing)
-			}
+// filterThings returns things accepted by filter, all things are returned
+// if filter is nil
+func filterThings(things []Thing, filter ThingFilterFunctionType) []Thing {
+
+	if filter == nil {
+		return things
+	}
+
+	var result []Thing
+	for _, thing := range things {
+		if filter(&thing) {
+			result = append(result, thing)
+		}
+	}
+
+	return result
+}
+
+// GetThingParentsContext returns map of thing id -> parent thing id. Servers which
+// don't support parent relation of things return GraphQL error.
+func (c *Client) GetThingParentsContext(ctx context.Context, all bool) (map[string]string, error) {
+
+	gql := `query ($all: Boolean) { things (all: $all) { id, parent {id} } }`
+
+	return c.queryThingParents(ctx, gql, map[string]interface{}{"all": all})
+}
+
+// GetOrgThingParentsContext returns map of thing id -> parent thing id for
+// things of given organization (see GetOrgThingsContext)
+func (c *Client) GetOrgThingParentsContext(ctx context.Context, orgId string) (map[string]string, error) {
+
+	gql := `query ($org_id: ID!) { things (org_id: $org_id) { id, parent {id} } }`
+
+	return c.queryThingParents(ctx, gql, map[string]interface{}{"org_id": orgId})
+}
+
+func (c *Client) queryThingParents(ctx context.Context, gql string, variables map[string]interface{}) (map[string]string, error) {
+
+	resp, err := c.gqlQuerySuccessful(ctx, gql, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Data struct {
+			Things []struct {
+				Id     string `json:"id"`
+				Parent *struct {
+					Id string `json:"id"`
+				} `json:"parent"`
+			} `json:"things"`
+		}
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]string{}
+	for _, thing := range data.Data.Things {
+		if thing.Parent != nil && thing.Parent.Id != "" {
+			result[thing.Id] = thing.Parent.Id
 		}
-		result = filteredResult
 	}
 
 	return result, nil
 }
 
+// list of organization fields fetched from the server
+const gqlOrgFields = "id, name, description, influxdb"
+
 type OrgFilterFunctionType = func(s *Org) bool
 
-func (c *Client) GetOrgs(filter OrgFilterFunctionType) ([]Org, error) {
+func (c *Client) GetOrgsContext(ctx context.Context, filter OrgFilterFunctionType) ([]Org, error) {
 
 	var result []Org
 
-	gql := "{orgs {id, name, influxdb}}"
+	gql := fmt.Sprintf("{orgs {%s}}", gqlOrgFields)
 
-	resp, err := c.gqlQuerySuccessful(gql)
+	resp, err := c.gqlQuerySuccessful(ctx, gql, nil)
 	if err != nil {
 		return result, err
 	}
@@ -315,9 +510,9 @@ func (c *Client) GetOrgs(filter OrgFilterFunctionType) ([]Org, error) {
 	return result, nil
 }
 
-func (c *Client) GetOrgByName(name string) (*Org, error) {
+func (c *Client) GetOrgByNameContext(ctx context.Context, name string) (*Org, error) {
 
-	orgs, err := c.GetOrgs(func(org *Org) bool { return org.Name == name })
+	orgs, err := c.GetOrgsContext(ctx, func(org *Org) bool { return org.Name == name })
 
 	if err != nil {
 		return nil, err
@@ -330,85 +525,177 @@ func (c *Client) GetOrgByName(name string) (*Org, error) {
 	return nil, nil
 }
 
-func (c *Client) SetCurrentOrg(name string) error {
+func (c *Client) SetCurrentOrgContext(ctx context.Context, name string) error {
 
-	org, err := c.GetOrgByName(name)
+	org, err := c.GetOrgByNameContext(ctx, name)
 	if err != nil {
 		return err
 	}
 
 	if org == nil {
-		return fmt.Errorf("Organization '%s' does not exist", name)
+		return fmt.Errorf("Organization '%s' does not exist: %w", name, ErrNotFound)
 	}
 
-	gql := fmt.Sprintf(`mutation { updateUserProfile(profile: {org_id: "%s"}) {org_id}}`, org.Id)
-	resp, err := c.gqlQuerySuccessful(gql)
+	_, err = c.UpdateUserProfileContext(ctx, &UserProfileAttributes{OrgId: &org.Id})
 
-	c.log.Infof("result of set: %v", resp.Body)
 	return err
 }
 
-func (c *Client) CreateThing(name, thing_type string) (string, error) {
+// CreateThingContext creates thing and sets its attributes. Thing stays on
+// server if attributes cannot be set, created thing is returned together with
+// error naming its id in such case.
+func (c *Client) CreateThingContext(ctx context.Context, name, thing_type string, attrs *ThingAttributes) (*Thing, error) {
 
 	c.log.Infof("Creating new thing: name='%s', type='%s'", name, thing_type)
 
 	gql := fmt.Sprintf(`
-		mutation {
-			createThing(name: "%s", type: "%s") {id}
+		mutation ($name: String!, $type: String!) {
+			createThing(name: $name, type: $type) {%s}
 		}
-	`, name, thing_type)
+	`, gqlThingFields)
 
-	resp, err := c.gqlQuerySuccessful(gql)
+	resp, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{
+		"name": name,
+		"type": thing_type,
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	c.log.Infof("%v", resp)
+	var data struct {
+		Data struct {
+			Thing Thing `json:"createThing"`
+		}
+	}
 
-	/*
-		var data struct {
-			Data struct {
-				Thing Thing `json:"thing"`
-			}
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	thing := data.Data.Thing
+
+	if attrs != nil && !attrs.IsEmpty() {
+		updated, err := c.UpdateThingContext(ctx, thing.Id, attrs)
+		if err != nil {
+			return &thing, fmt.Errorf("Thing '%s' created (%s), but its attributes could not be set: %w", thing.Name, thing.Id, err)
+		}
+		return updated, nil
+	}
+
+	return &thing, nil
+}
+
+// UpdateThingContext changes attributes of existing thing and returns thing state
+// after the update
+func (c *Client) UpdateThingContext(ctx context.Context, id string, attrs *ThingAttributes) (*Thing, error) {
+
+	c.log.Infof("Updating thing: id='%s'", id)
+
+	thingFields := map[string]interface{}{}
+	sensorFields := map[string]interface{}{}
+
+	if attrs.Alias != nil {
+		thingFields["alias"] = *attrs.Alias
+	}
+	if attrs.Enabled != nil {
+		thingFields["enabled"] = *attrs.Enabled
+	}
+	if attrs.LastSeenInterval != nil {
+		thingFields["last_seen_interval"] = *attrs.LastSeenInterval
+	}
+	if attrs.StoreInfluxDb != nil {
+		thingFields["store_influxdb"] = *attrs.StoreInfluxDb
+	}
+	if attrs.StoreMysqlDb != nil {
+		thingFields["store_mysqldb"] = *attrs.StoreMysqlDb
+	}
+	if attrs.SensorClass != nil {
+		sensorFields["class"] = *attrs.SensorClass
+	}
+	if attrs.SensorUnit != nil {
+		sensorFields["unit"] = *attrs.SensorUnit
+	}
+
+	if len(thingFields) > 0 {
+		thingFields["id"] = id
+		_, err := c.gqlQuerySuccessful(ctx,
+			`mutation ($thing: ThingUpdateInput!) { updateThing(thing: $thing) }`,
+			map[string]interface{}{"thing": thingFields},
+		)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		err = json.NewDecoder(resp.Body).Decode(&data)
+	if len(sensorFields) > 0 {
+		sensorFields["id"] = id
+		_, err := c.gqlQuerySuccessful(ctx,
+			`mutation ($data: ThingSensorDataUpdateInput!) { updateThingSensorData(data: $data) }`,
+			map[string]interface{}{"data": sensorFields},
+		)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+	}
 
-		return data.Thing.Id, nil
-	*/
-	return "id", nil
+	return c.GetThingContext(ctx, id)
 }
 
-func (c *Client) DeleteThing() error {
+func (c *Client) GetThingContext(ctx context.Context, id string) (*Thing, error) {
 
-	jsonData := map[string]string{
-		"query": fmt.Sprintf(`
-			mutation {
-				deleteThing(id : "%s")
+	gql := fmt.Sprintf(`
+		query ($id: ID!) {
+			thing (id: $id) {
+				%s
 			}
-		`, "idtodel"),
+		}
+		`, gqlThingFields)
+
+	resp, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
 	}
 
-	c.log.Infof("%v", jsonData)
+	var data struct {
+		Data struct {
+			Thing *Thing `json:"thing"`
+		}
+	}
 
-	return nil
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Data.Thing == nil {
+		return nil, fmt.Errorf("Thing '%s' does not exist: %w", id, ErrNotFound)
+	}
+
+	return data.Data.Thing, nil
 }
 
-func (c *Client) GetUserProfile() (UserProfile, error) {
+func (c *Client) DeleteThingContext(ctx context.Context, id string) error {
 
-	var result UserProfile
+	c.log.Infof("Deleting thing: id='%s'", id)
 
 	gql := `
-		{
-			userProfile {
-				email, is_admin, org_id, orgs {id, name, influxdb} 
-			}
+		mutation ($id: ID!) {
+			deleteThing(id: $id)
 		}
 	`
-	resp, err := c.gqlQuerySuccessful(gql)
+
+	_, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"id": id})
+
+	return err
+}
+
+func (c *Client) GetUserProfileContext(ctx context.Context) (UserProfile, error) {
+
+	var result UserProfile
+
+	gql := fmt.Sprintf(`{ userProfile {%s} }`, gqlUserProfileFields)
+	resp, err := c.gqlQuerySuccessful(ctx, gql, nil)
 	if err != nil {
 		return result, err
 	}
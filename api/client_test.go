@@ -0,0 +1,313 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"piot-cli/api"
+	"piot-cli/apitest"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("api_test")
+
+func init() {
+	logging.SetLevel(logging.ERROR, "api_test")
+}
+
+// names which would break query or inject another operation if they were
+// formatted into query text
+var hostileNames = []string{
+	`quoted "name"`,
+	`back\slash\`,
+	"multi\nline\r\nname",
+	`brace} name {`,
+	`x") } mutation { deleteThing(id: "thing1") } query { things(all: true`,
+	`} mutation { deleteThing(id: "thing1") }`,
+	`$all`,
+}
+
+// roundTrip creates thing, organization and user named by value, reads them
+// back and returns read values and texts of all sent queries
+func roundTrip(t *testing.T, value string) (*apitest.Server, []string, []string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	server := apitest.NewServer()
+	t.Cleanup(server.Close)
+
+	client := server.NewClient(log)
+	if err := client.LoginContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var values []string
+
+	thing, err := client.CreateThingContext(ctx, value, "sensor", &api.ThingAttributes{Alias: &value, SensorUnit: &value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thing, err = client.GetThingContext(ctx, thing.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values = append(values, thing.Name, thing.Alias, thing.Sensor.Unit)
+
+	_, err = client.CreateOrgContext(ctx, &api.OrgAttributes{Name: &value, Description: &value, InfluxDb: &value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	org, err := client.GetOrgByNameContext(ctx, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if org == nil {
+		t.Fatalf("organization %q not found", value)
+	}
+	org, err = client.GetOrgContext(ctx, org.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values = append(values, org.Name, org.Description, org.InfluxDb)
+
+	user, err := client.CreateUserContext(ctx, &api.UserAttributes{Email: &value, Password: &value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddOrgMemberContext(ctx, org.Id, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	user, err = client.GetUserByEmailContext(ctx, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil {
+		t.Fatalf("user %q not found", value)
+	}
+	values = append(values, user.Email)
+	for _, o := range user.Orgs {
+		values = append(values, o.Name)
+	}
+
+	var queries []string
+	for _, request := range server.Requests() {
+		queries = append(queries, request.Query)
+	}
+
+	return server, values, queries
+}
+
+// variableStrings returns all string values nested in request variables
+func variableStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case map[string]interface{}:
+		var result []string
+		for _, item := range v {
+			result = append(result, variableStrings(item)...)
+		}
+		return result
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			result = append(result, variableStrings(item)...)
+		}
+		return result
+	}
+	return nil
+}
+
+func TestHostileNamesAreSentAsVariables(t *testing.T) {
+
+	_, _, plainQueries := roundTrip(t, "plain")
+
+	for _, name := range hostileNames {
+
+		server, values, queries := roundTrip(t, name)
+
+		// values are not altered on the way to server and back
+		for i, value := range values {
+			if value != name {
+				t.Errorf("%q: value %d came back as %q", name, i, value)
+			}
+		}
+
+		// query text doesn't depend on values
+		if !reflect.DeepEqual(queries, plainQueries) {
+			t.Errorf("%q: queries differ from queries of plain name:\n%q\n%q", name, queries, plainQueries)
+		}
+
+		// value is sent in variables only
+		sent := 0
+		for _, request := range server.Requests() {
+			if strings.Contains(request.Query, name) {
+				t.Errorf("%q: value found in query %q", name, request.Query)
+			}
+			for _, s := range variableStrings(map[string]interface{}(request.Variables)) {
+				if s == name {
+					sent++
+				}
+			}
+		}
+		if sent == 0 {
+			t.Errorf("%q: value not found in variables of any request", name)
+		}
+
+		// injected operations are not executed
+		if things := server.Things(); len(things) != 1 {
+			t.Errorf("%q: expected 1 thing, got %+v", name, things)
+		}
+	}
+}
+
+func TestMissingObjectsAreNotFound(t *testing.T) {
+
+	ctx := context.Background()
+
+	server := apitest.NewServer()
+	defer server.Close()
+
+	client := server.NewClient(log)
+	if err := client.LoginContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	lookups := map[string]func() error{
+		"GetThingContext": func() error {
+			_, err := client.GetThingContext(ctx, "missing")
+			return err
+		},
+		"GetOrgContext": func() error {
+			_, err := client.GetOrgContext(ctx, "missing")
+			return err
+		},
+		"GetOrgMembersContext": func() error {
+			_, err := client.GetOrgMembersContext(ctx, "missing")
+			return err
+		},
+		"GetUserContext": func() error {
+			_, err := client.GetUserContext(ctx, "missing")
+			return err
+		},
+		"SetCurrentOrgContext": func() error {
+			return client.SetCurrentOrgContext(ctx, "missing")
+		},
+	}
+
+	for name, lookup := range lookups {
+		err := lookup()
+		if !errors.Is(err, api.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", name, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "'missing' does not exist") {
+			t.Errorf("%s: unexpected message %q", name, err)
+		}
+	}
+}
+
+func TestCreateThingReportsFailedUpdate(t *testing.T) {
+
+	ctx := context.Background()
+
+	server := apitest.NewServer()
+	defer server.Close()
+
+	client := server.NewClient(log)
+	if err := client.LoginContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Fail("updateThingSensorData", "BAD_USER_INPUT", "Unknown sensor class")
+
+	class := "temperature"
+	alias := "Kitchen"
+	thing, err := client.CreateThingContext(ctx, "B3007-Temp", "sensor", &api.ThingAttributes{Alias: &alias, SensorClass: &class})
+	if thing == nil || thing.Id == "" {
+		t.Fatalf("created thing is not returned: %+v", thing)
+	}
+
+	expected := "Thing 'B3007-Temp' created (" + thing.Id + "), but its attributes could not be set"
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+	if !errors.Is(err, api.ErrValidation) {
+		t.Errorf("expected wrapped ErrValidation, got %v", err)
+	}
+
+	// thing is kept on server, attributes set before failure are stored
+	things := server.Things()
+	if len(things) != 1 || things[0].Id != thing.Id || things[0].Alias != "Kitchen" {
+		t.Errorf("unexpected things on server: %+v", things)
+	}
+}
+
+// things of organization which is not active are fetched by member which is
+// not admin, org_id is not requested from server
+func TestGetOrgThingsNotAdmin(t *testing.T) {
+
+	ctx := context.Background()
+
+	server := apitest.NewServer()
+	t.Cleanup(server.Close)
+
+	home := server.AddOrg(api.Org{Name: "HOME"})
+	server.AddThing(api.Thing{Name: "H1", Type: "device", OrgId: home.Id})
+	cottage := server.AddOrg(api.Org{Name: "COTTAGE"})
+	c1 := server.AddThing(api.Thing{Name: "C1", Type: "device", OrgId: cottage.Id})
+	c1Temp := server.AddThing(api.Thing{Name: "C1-Temp", Type: "sensor", OrgId: cottage.Id})
+	server.SetThingParent(c1Temp.Id, c1.Id)
+	server.SetAdmin(false)
+
+	client := server.NewClient(log)
+	if err := client.LoginContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	things, err := client.GetOrgThingsContext(ctx, cottage.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, thing := range things {
+		if thing.OrgId != cottage.Id {
+			t.Errorf("expected org %s of thing %s, got %q", cottage.Id, thing.Name, thing.OrgId)
+		}
+		names = append(names, thing.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"C1", "C1-Temp"}) {
+		t.Errorf("unexpected things %v", names)
+	}
+
+	parents, err := client.GetOrgThingParentsContext(ctx, cottage.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parents, map[string]string{c1Temp.Id: c1.Id}) {
+		t.Errorf("unexpected parents %v", parents)
+	}
+
+	for _, r := range server.Requests() {
+		if _, ok := r.Variables["all"]; ok {
+			t.Errorf("unexpected query of all things: %s", r.Query)
+		}
+		i := strings.Index(r.Query, "things")
+		if i < 0 {
+			continue
+		}
+		selection := r.Query[i:]
+		if strings.Contains(selection[strings.Index(selection, "{"):], "org_id") {
+			t.Errorf("unexpected request of org_id field: %s", r.Query)
+		}
+	}
+
+	// all things are available to admins only
+	if _, err := client.GetThingsContext(ctx, true, nil); !errors.Is(err, api.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+}
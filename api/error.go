@@ -1,11 +1,101 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 )
 
+// sentinel errors for known failures, use errors.Is to check them
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrValidation   = errors.New("validation failed")
+)
+
+// known codes reported by server in extensions of GraphQL errors
+var gqlErrorCodes = map[string]error{
+	"UNAUTHORIZED":              ErrUnauthorized,
+	"UNAUTHENTICATED":           ErrUnauthorized,
+	"FORBIDDEN":                 ErrUnauthorized,
+	"NOT_FOUND":                 ErrNotFound,
+	"VALIDATION":                ErrValidation,
+	"VALIDATION_ERROR":          ErrValidation,
+	"BAD_USER_INPUT":            ErrValidation,
+	"GRAPHQL_VALIDATION_FAILED": ErrValidation,
+}
+
+// Code returns error code from extensions or empty string if server didn't
+// provide it
+func (e *GqlError) Code() string {
+	if code, ok := e.Extensions["code"].(string); ok {
+		return code
+	}
+	return ""
+}
+
+func (e *GqlError) Error() string {
+
+	result := fmt.Sprintf("GraphQL error: msg='%s'", e.Message)
+
+	for _, location := range e.Locations {
+		result += fmt.Sprintf(" line=%d, col=%d", location.Line, location.Column)
+	}
+
+	if len(e.Path) > 0 {
+		var path []string
+		for _, item := range e.Path {
+			path = append(path, fmt.Sprintf("%v", item))
+		}
+		result += fmt.Sprintf(" path=%s", strings.Join(path, "."))
+	}
+
+	if code := e.Code(); code != "" {
+		result += fmt.Sprintf(" code=%s", code)
+	}
+
+	return result
+}
+
+// Is maps known error codes to sentinel errors
+func (e *GqlError) Is(target error) bool {
+	return gqlErrorCodes[strings.ToUpper(e.Code())] == target
+}
+
+// GqlErrors holds all errors reported in GraphQL response
+type GqlErrors []GqlError
+
+func (e GqlErrors) Error() string {
+
+	var result []string
+	for i := range e {
+		result = append(result, e[i].Error())
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// Is returns true if any of errors matches target
+func (e GqlErrors) Is(target error) bool {
+	for i := range e {
+		if e[i].Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As supports extraction of first error by errors.As with *GqlError target
+func (e GqlErrors) As(target interface{}) bool {
+	if t, ok := target.(**GqlError); ok && len(e) > 0 {
+		*t = &e[0]
+		return true
+	}
+	return false
+}
+
 type ApiError struct{
 	Response *http.Response
 }
@@ -26,6 +116,25 @@ func (e *ApiError) Error() string {
 	return "PIOT Api Call Error"
 }
 
+// Is maps http status codes to sentinel errors
+func (e *ApiError) Is(target error) bool {
+
+	if e.Response == nil {
+		return false
+	}
+
+	switch e.Response.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return target == ErrUnauthorized
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return target == ErrValidation
+	}
+
+	return false
+}
+
 func isApiAuthError(err error) bool {
 
 	// try to typecast err to ApiError
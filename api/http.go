@@ -0,0 +1,140 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	DEFAULT_TIMEOUT         = time.Minute
+	DEFAULT_CONNECT_TIMEOUT = 10 * time.Second
+)
+
+// HTTPConfig holds connection settings shared by PIOT API and InfluxDB
+// clients
+type HTTPConfig struct {
+	// Timeout limits whole request including reading of response body,
+	// zero means no limit
+	Timeout time.Duration
+
+	// ConnectTimeout limits establishing of connection (including TLS
+	// handshake), zero means no limit
+	ConnectTimeout time.Duration
+
+	// Retries is maximal number of retries of idempotent requests which
+	// failed for transient reason
+	Retries int
+
+	// CAFile is bundle of PEM encoded certificates of trusted authorities,
+	// they are trusted together with system authorities
+	CAFile string
+
+	// CertFile and KeyFile hold PEM encoded client certificate and its
+	// private key (mutual TLS)
+	CertFile string
+	KeyFile  string
+
+	// InsecureSkipVerify disables verification of server certificate
+	InsecureSkipVerify bool
+
+	// Proxy is url of HTTP proxy, proxy is taken from HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY environment variables if empty
+	Proxy string
+}
+
+// HTTPConfigFromViper reads http.* configuration keys
+func HTTPConfigFromViper() HTTPConfig {
+	return HTTPConfig{
+		Timeout:        viper.GetDuration("http.timeout"),
+		ConnectTimeout: viper.GetDuration("http.connect_timeout"),
+		Retries:        viper.GetInt("http.retries"),
+
+		CAFile:             viper.GetString("tls.ca_file"),
+		CertFile:           viper.GetString("tls.cert_file"),
+		KeyFile:            viper.GetString("tls.key_file"),
+		InsecureSkipVerify: viper.GetBool("tls.insecure_skip_verify"),
+		Proxy:              viper.GetString("http.proxy"),
+	}
+}
+
+func newTLSConfig(conf HTTPConfig) (*tls.Config, error) {
+
+	result := &tls.Config{InsecureSkipVerify: conf.InsecureSkipVerify}
+
+	if conf.CAFile != "" {
+		pem, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read CA bundle: %v", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CA bundle '%s'", conf.CAFile)
+		}
+		result.RootCAs = pool
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return nil, fmt.Errorf("Both client certificate and key must be set")
+		}
+
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot load client certificate: %v", err)
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+
+	return result, nil
+}
+
+// NewHTTPClient creates http client which is reused for all requests, so
+// connections are kept alive between requests
+func NewHTTPClient(conf HTTPConfig) (*http.Client, error) {
+
+	tlsConfig, err := newTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if conf.Proxy != "" {
+		proxyUrl, err := url.Parse(conf.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy url: %v", err)
+		}
+		proxy = http.ProxyURL(proxyUrl)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   conf.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   conf.ConnectTimeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{
+		Timeout:   conf.Timeout,
+		Transport: transport,
+	}, nil
+}
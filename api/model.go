@@ -1,8 +1,11 @@
 package api
 
+import "encoding/json"
+
 type SensorData struct {
 	Value string `json:"value" csv:"value"`
 	Class string `json:"class" csv:"class"`
+	Unit  string `json:"unit" csv:"unit"`
 }
 
 type Thing struct {
@@ -16,12 +19,60 @@ type Thing struct {
 	StoreInfluxDb    bool       `json:"store_influxdb" csv:"store_influxdb"`
 	StoreMysqlDb     bool       `json:"store_mysqldb" csv:"store_mysqldb"`
 	Sensor           SensorData `json:"sensor" csv:"sensor_,inline"`
+	OrgId            string     `json:"org_id,omitempty" csv:"org_id"`
+}
+
+// ThingAttributes holds editable attributes of thing. Only attributes with
+// non-nil value are sent to the server.
+type ThingAttributes struct {
+	Alias            *string
+	Enabled          *bool
+	LastSeenInterval *int32
+	StoreInfluxDb    *bool
+	StoreMysqlDb     *bool
+	SensorClass      *string
+	SensorUnit       *string
+}
+
+// IsEmpty returns true if no attribute is set
+func (a *ThingAttributes) IsEmpty() bool {
+	return a.Alias == nil &&
+		a.Enabled == nil &&
+		a.LastSeenInterval == nil &&
+		a.StoreInfluxDb == nil &&
+		a.StoreMysqlDb == nil &&
+		a.SensorClass == nil &&
+		a.SensorUnit == nil
 }
 
 type Org struct {
-	Id       string `json:"id"`
-	Name     string `json:"name"`
-	InfluxDb string `json:"influxdb"`
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InfluxDb    string `json:"influxdb"`
+}
+
+// OrgAttributes holds editable attributes of organization. Only attributes
+// with non-nil value are sent to the server.
+type OrgAttributes struct {
+	Name        *string
+	Description *string
+	InfluxDb    *string
+}
+
+type User struct {
+	Id      string `json:"id"`
+	Email   string `json:"email"`
+	IsAdmin bool   `json:"is_admin"`
+	Orgs    []Org  `json:"orgs"`
+}
+
+// UserAttributes holds user attributes to be set on create or update,
+// nil values are not changed
+type UserAttributes struct {
+	Email    *string
+	Password *string
+	IsAdmin  *bool
 }
 
 type GqlLocation struct {
@@ -29,12 +80,16 @@ type GqlLocation struct {
 	Column int `json:"column"`
 }
 
+// GqlError is single error reported by GraphQL server, extensions are
+// server specific (e.g. error code)
 type GqlError struct {
-	Message   string        `json:"message"`
-	Locations []GqlLocation `json:"locations"`
-	Path      []interface{} `json:"path"`
+	Message    string                 `json:"message"`
+	Locations  []GqlLocation          `json:"locations"`
+	Path       []interface{}          `json:"path"`
+	Extensions map[string]interface{} `json:"extensions"`
 }
 
 type GqlResponse struct {
-	Errors map[string]string `json:"errors"`
+	Data   json.RawMessage `json:"data"`
+	Errors GqlErrors       `json:"errors"`
 }
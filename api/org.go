@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+func orgAttributesFields(attrs *OrgAttributes) map[string]interface{} {
+
+	fields := map[string]interface{}{}
+
+	if attrs.Name != nil {
+		fields["name"] = *attrs.Name
+	}
+	if attrs.Description != nil {
+		fields["description"] = *attrs.Description
+	}
+	if attrs.InfluxDb != nil {
+		fields["influxdb"] = *attrs.InfluxDb
+	}
+
+	return fields
+}
+
+func (c *Client) GetOrgContext(ctx context.Context, id string) (*Org, error) {
+
+	orgs, err := c.GetOrgsContext(ctx, func(org *Org) bool { return org.Id == id })
+	if err != nil {
+		return nil, err
+	}
+
+	if len(orgs) == 0 {
+		return nil, fmt.Errorf("Organization '%s' does not exist: %w", id, ErrNotFound)
+	}
+
+	return &orgs[0], nil
+}
+
+func (c *Client) CreateOrgContext(ctx context.Context, attrs *OrgAttributes) (*Org, error) {
+
+	c.log.Infof("Creating new organization")
+
+	gql := fmt.Sprintf(`mutation ($org: OrgCreateInput!) { createOrg(org: $org) {%s} }`, gqlOrgFields)
+
+	resp, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"org": orgAttributesFields(attrs)})
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Data struct {
+			Org Org `json:"createOrg"`
+		}
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.Data.Org, nil
+}
+
+// UpdateOrgContext changes attributes of existing organization and returns its state
+// after the update
+func (c *Client) UpdateOrgContext(ctx context.Context, id string, attrs *OrgAttributes) (*Org, error) {
+
+	c.log.Infof("Updating organization: id='%s'", id)
+
+	fields := orgAttributesFields(attrs)
+	fields["id"] = id
+
+	_, err := c.gqlQuerySuccessful(ctx,
+		`mutation ($org: OrgUpdateInput!) { updateOrg(org: $org) }`,
+		map[string]interface{}{"org": fields},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetOrgContext(ctx, id)
+}
+
+func (c *Client) DeleteOrgContext(ctx context.Context, id string) error {
+
+	c.log.Infof("Deleting organization: id='%s'", id)
+
+	gql := `mutation ($id: ID!) { deleteOrg(id: $id) }`
+
+	_, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"id": id})
+
+	return err
+}
+
+func (c *Client) GetOrgMembersContext(ctx context.Context, orgId string) ([]User, error) {
+
+	gql := `query ($id: ID!) { org (id: $id) { users {id, email} } }`
+
+	resp, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"id": orgId})
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Data struct {
+			Org *struct {
+				Users []User `json:"users"`
+			} `json:"org"`
+		}
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Data.Org == nil {
+		return nil, fmt.Errorf("Organization '%s' does not exist: %w", orgId, ErrNotFound)
+	}
+
+	return data.Data.Org.Users, nil
+}
+
+func (c *Client) AddOrgMemberContext(ctx context.Context, orgId, userId string) error {
+
+	c.log.Infof("Adding user '%s' to organization '%s'", userId, orgId)
+
+	gql := `mutation ($org_id: ID!, $user_id: ID!) { addOrgUser(org_id: $org_id, user_id: $user_id) }`
+
+	_, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"org_id": orgId, "user_id": userId})
+
+	return err
+}
+
+func (c *Client) RemoveOrgMemberContext(ctx context.Context, orgId, userId string) error {
+
+	c.log.Infof("Removing user '%s' from organization '%s'", userId, orgId)
+
+	gql := `mutation ($org_id: ID!, $user_id: ID!) { removeOrgUser(org_id: $org_id, user_id: $user_id) }`
+
+	_, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"org_id": orgId, "user_id": userId})
+
+	return err
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+const (
+	DEFAULT_RETRIES = 3
+
+	// wait before first retry, it is doubled for every next attempt
+	RETRY_MIN_WAIT = 500 * time.Millisecond
+	RETRY_MAX_WAIT = 30 * time.Second
+)
+
+var (
+	retryRandMutex sync.Mutex
+	retryRand      = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// isRetryableStatus returns true for statuses of transient failures
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || (code >= 500 && code != http.StatusNotImplemented)
+}
+
+// retryWait returns wait before next attempt, Retry-After header of response
+// (seconds or http date) takes precedence over exponential backoff with jitter.
+// Wait is never longer than RETRY_MAX_WAIT.
+func retryWait(attempt int, resp *http.Response) time.Duration {
+
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > RETRY_MAX_WAIT {
+				return RETRY_MAX_WAIT
+			}
+			return wait
+		}
+	}
+
+	wait := RETRY_MIN_WAIT << uint(attempt)
+	if wait > RETRY_MAX_WAIT || wait <= 0 {
+		wait = RETRY_MAX_WAIT
+	}
+
+	// random wait from interval <wait/2, wait) spreads retries of clients
+	retryRandMutex.Lock()
+	jitter := time.Duration(retryRand.Int63n(int64(wait / 2)))
+	retryRandMutex.Unlock()
+
+	return wait/2 + jitter
+}
+
+// retryAfter parses value of Retry-After header (seconds or http date), dates
+// in past give zero wait
+func retryAfter(value string) (time.Duration, bool) {
+
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		// huge values would overflow duration
+		if seconds > int64(RETRY_MAX_WAIT/time.Second) {
+			return RETRY_MAX_WAIT, true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// IsIdempotentGql returns true for GraphQL queries, mutations are never
+// retried since their effect could be applied twice
+func IsIdempotentGql(gql string) bool {
+	return !strings.HasPrefix(strings.TrimSpace(gql), "mutation")
+}
+
+// DoWithRetry sends request created by newRequest (body cannot be reused for
+// next attempt). Network errors, 5xx and 429 responses are retried up to
+// retries times if retry is allowed.
+func DoWithRetry(ctx context.Context, client *http.Client, retries int, logger *logging.Logger, newRequest func() (*http.Request, error)) (*http.Response, error) {
+
+	for attempt := 0; ; attempt++ {
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+
+		failed := err != nil || isRetryableStatus(resp.StatusCode)
+		if !failed || attempt >= retries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+
+		wait := retryWait(attempt, resp)
+
+		logger.Infof("Request to %s failed (%s), retrying in %s (attempt %d of %d)",
+			req.URL.Host, reason, wait.Truncate(time.Millisecond), attempt+1, retries)
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
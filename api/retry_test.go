@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryWait(t *testing.T) {
+
+	response := func(retryAfter string) *http.Response {
+		resp := &http.Response{Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+
+	cases := []struct {
+		name       string
+		retryAfter string
+		min, max   time.Duration
+	}{
+		{"seconds", "5", 5 * time.Second, 5 * time.Second},
+		{"large seconds", "86400", RETRY_MAX_WAIT, RETRY_MAX_WAIT},
+		{"overflowing seconds", "99999999999999999", RETRY_MAX_WAIT, RETRY_MAX_WAIT},
+		{"date", time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{"far date", time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat), RETRY_MAX_WAIT, RETRY_MAX_WAIT},
+		{"past date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+		{"invalid", "soon", RETRY_MIN_WAIT / 2, RETRY_MIN_WAIT},
+		{"missing", "", RETRY_MIN_WAIT / 2, RETRY_MIN_WAIT},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			wait := retryWait(0, response(c.retryAfter))
+			if wait < c.min || wait > c.max {
+				t.Errorf("expected wait in <%s, %s>, got %s", c.min, c.max, wait)
+			}
+		})
+	}
+}
+
+func TestRetryWaitBackoff(t *testing.T) {
+
+	for attempt := 0; attempt < 100; attempt++ {
+		wait := retryWait(attempt, nil)
+		if wait <= 0 || wait > RETRY_MAX_WAIT {
+			t.Errorf("unexpected wait %s of attempt %d", wait, attempt)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	TOKEN_CACHE_DIR  = "piot"
+	TOKEN_CACHE_FILE = "tokens.json"
+)
+
+type cachedToken struct {
+	Token   string    `json:"token"`
+	Created time.Time `json:"created"`
+}
+
+// TokenCache persists login tokens between invocations. Tokens are keyed by
+// server url and user, cache file is readable by owner only.
+type TokenCache struct {
+	path string
+}
+
+func NewTokenCache() (*TokenCache, error) {
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenCache{path: filepath.Join(dir, TOKEN_CACHE_DIR, TOKEN_CACHE_FILE)}, nil
+}
+
+func (c *TokenCache) Path() string {
+	return c.path
+}
+
+func tokenCacheKey(url, user string) string {
+	return url + " " + user
+}
+
+func (c *TokenCache) load() (map[string]cachedToken, error) {
+
+	tokens := map[string]cachedToken{}
+
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (c *TokenCache) save(tokens map[string]cachedToken) error {
+
+	err := os.MkdirAll(filepath.Dir(c.path), 0700)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(c.path, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	// permissions of existing file are not changed by WriteFile
+	return os.Chmod(c.path, 0600)
+}
+
+// Get returns cached token or empty string if there is no token for given
+// server and user
+func (c *TokenCache) Get(url, user string) (string, error) {
+
+	tokens, err := c.load()
+	if err != nil {
+		return "", err
+	}
+
+	return tokens[tokenCacheKey(url, user)].Token, nil
+}
+
+func (c *TokenCache) Set(url, user, token string) error {
+
+	tokens, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	tokens[tokenCacheKey(url, user)] = cachedToken{Token: token, Created: time.Now()}
+
+	return c.save(tokens)
+}
+
+func (c *TokenCache) Delete(url, user string) error {
+
+	tokens, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	key := tokenCacheKey(url, user)
+	if _, ok := tokens[key]; !ok {
+		return nil
+	}
+	delete(tokens, key)
+
+	return c.save(tokens)
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+type UserFilterFunctionType = func(u *User) bool
+
+const gqlUserFields = "id, email, is_admin, orgs {id, name}"
+
+func userAttributesFields(attrs *UserAttributes) map[string]interface{} {
+
+	fields := map[string]interface{}{}
+
+	if attrs.Email != nil {
+		fields["email"] = *attrs.Email
+	}
+	if attrs.Password != nil {
+		fields["password"] = *attrs.Password
+	}
+	if attrs.IsAdmin != nil {
+		fields["is_admin"] = *attrs.IsAdmin
+	}
+
+	return fields
+}
+
+func (c *Client) GetUsersContext(ctx context.Context, filter UserFilterFunctionType) ([]User, error) {
+
+	var result []User
+
+	resp, err := c.gqlQuerySuccessful(ctx, fmt.Sprintf("{ users {%s} }", gqlUserFields), nil)
+	if err != nil {
+		return result, err
+	}
+
+	var data struct {
+		Data struct {
+			Users []User `json:"users"`
+		}
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return result, err
+	}
+
+	result = data.Data.Users
+
+	// apply filtering if filter function was provided
+	if filter != nil {
+		var filteredResult []User
+		for _, u := range data.Data.Users {
+			if filter(&u) {
+				filteredResult = append(filteredResult, u)
+			}
+		}
+		result = filteredResult
+	}
+
+	return result, nil
+}
+
+func (c *Client) GetUserContext(ctx context.Context, id string) (*User, error) {
+
+	users, err := c.GetUsersContext(ctx, func(user *User) bool { return user.Id == id })
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, fmt.Errorf("User '%s' does not exist: %w", id, ErrNotFound)
+	}
+
+	return &users[0], nil
+}
+
+func (c *Client) GetUserByEmailContext(ctx context.Context, email string) (*User, error) {
+
+	users, err := c.GetUsersContext(ctx, func(user *User) bool { return user.Email == email })
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) > 0 {
+		return &users[0], nil
+	}
+
+	return nil, nil
+}
+
+func (c *Client) CreateUserContext(ctx context.Context, attrs *UserAttributes) (*User, error) {
+
+	c.log.Infof("Creating new user")
+
+	gql := fmt.Sprintf(`mutation ($user: UserCreateInput!) { createUser(user: $user) {%s} }`, gqlUserFields)
+
+	resp, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"user": userAttributesFields(attrs)})
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Data struct {
+			User User `json:"createUser"`
+		}
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.Data.User, nil
+}
+
+// UpdateUserContext changes attributes of existing user and returns its state after
+// the update
+func (c *Client) UpdateUserContext(ctx context.Context, id string, attrs *UserAttributes) (*User, error) {
+
+	c.log.Infof("Updating user: id='%s'", id)
+
+	fields := userAttributesFields(attrs)
+	fields["id"] = id
+
+	_, err := c.gqlQuerySuccessful(ctx,
+		`mutation ($user: UserUpdateInput!) { updateUser(user: $user) }`,
+		map[string]interface{}{"user": fields},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetUserContext(ctx, id)
+}
+
+func (c *Client) DeleteUserContext(ctx context.Context, id string) error {
+
+	c.log.Infof("Deleting user: id='%s'", id)
+
+	gql := `mutation ($id: ID!) { deleteUser(id: $id) }`
+
+	_, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"id": id})
+
+	return err
+}
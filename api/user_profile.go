@@ -1,6 +1,10 @@
 package api
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
 
 type UserProfile struct {
 	Email   string `json:"email"`
@@ -19,3 +23,77 @@ func (p *UserProfile) GetActiveOrg() (*Org, error) {
 
 	return nil, fmt.Errorf("No active organization in current profile.")
 }
+
+// UserProfileAttributes holds editable profile fields, nil values are not
+// changed
+type UserProfileAttributes struct {
+	Email *string
+	OrgId *string
+}
+
+const gqlUserProfileFields = "email, is_admin, org_id, orgs {id, name, influxdb}"
+
+// UpdateUserProfileContext changes fields of profile of logged user and returns
+// updated profile
+func (c *Client) UpdateUserProfileContext(ctx context.Context, attrs *UserProfileAttributes) (UserProfile, error) {
+
+	var result UserProfile
+
+	c.log.Infof("Updating user profile")
+
+	fields := map[string]interface{}{}
+	if attrs.Email != nil {
+		fields["email"] = *attrs.Email
+	}
+	if attrs.OrgId != nil {
+		fields["org_id"] = *attrs.OrgId
+	}
+
+	gql := fmt.Sprintf(`mutation ($profile: ProfileUpdateInput!) { updateUserProfile(profile: $profile) {%s} }`, gqlUserProfileFields)
+
+	resp, err := c.gqlQuerySuccessful(ctx, gql, map[string]interface{}{"profile": fields})
+	if err != nil {
+		return result, err
+	}
+
+	var data struct {
+		Data struct {
+			Profile UserProfile `json:"updateUserProfile"`
+		}
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return result, err
+	}
+
+	// following requests are authenticated by token, but credentials must
+	// match for re-login
+	if attrs.Email != nil {
+		c.user = *attrs.Email
+	}
+
+	return data.Data.Profile, nil
+}
+
+// ChangePasswordContext changes password of logged user, new password is used for
+// subsequent logins of this client
+func (c *Client) ChangePasswordContext(ctx context.Context, current, password string) error {
+
+	c.log.Infof("Changing password of user: %s", c.user)
+
+	_, err := c.gqlQuerySuccessful(ctx,
+		`mutation ($password: UserPasswordUpdateInput!) { updateUserPassword(password: $password) }`,
+		map[string]interface{}{"password": map[string]interface{}{
+			"password":     current,
+			"new_password": password,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+
+	c.password = password
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// golden files are rewritten by actual output instead of comparison if this
+// environment variable is set (e.g. after intended change of output format)
+const UPDATE_GOLDEN_ENV = "PIOT_UPDATE_GOLDEN"
+
+func updateGolden() bool {
+	return os.Getenv(UPDATE_GOLDEN_ENV) != ""
+}
+
+// CompareGolden compares output with content of golden file, error describes
+// first line which differs
+func CompareGolden(goldenPath string, actual []byte) error {
+
+	if updateGolden() {
+		return ioutil.WriteFile(goldenPath, actual, 0644)
+	}
+
+	expected, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(expected, actual) {
+		return nil
+	}
+
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var e, a string
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if e != a || i >= len(expectedLines) || i >= len(actualLines) {
+			return fmt.Errorf("Output differs from '%s' at line %d:\n  expected: %q\n  actual:   %q", goldenPath, i+1, e, a)
+		}
+	}
+
+	return fmt.Errorf("Output differs from '%s'", goldenPath)
+}
+
+// ExcelCells returns formatted values of cells of all sheets of xlsx file
+// (sheet name -> rows -> columns)
+func ExcelCells(path string) (map[string][][]string, error) {
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string][][]string{}
+	for _, sheet := range f.GetSheetList() {
+		rows, err := f.GetRows(sheet)
+		if err != nil {
+			return nil, err
+		}
+		result[sheet] = rows
+	}
+
+	return result, nil
+}
+
+// CompareExcelGolden compares cells of xlsx file with golden file, golden
+// file holds cells in json (see ExcelCells). All differing cells are
+// reported.
+func CompareExcelGolden(goldenPath string, path string) error {
+
+	actual, err := ExcelCells(path)
+	if err != nil {
+		return err
+	}
+
+	if updateGolden() {
+		content, err := json.MarshalIndent(actual, "", "  ")
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(goldenPath, append(content, '\n'), 0644)
+	}
+
+	content, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		return err
+	}
+
+	var expected map[string][][]string
+	err = json.Unmarshal(content, &expected)
+	if err != nil {
+		return fmt.Errorf("Cannot parse golden file '%s': %v", goldenPath, err)
+	}
+
+	var diffs []string
+
+	for sheet := range actual {
+		if _, ok := expected[sheet]; !ok {
+			diffs = append(diffs, fmt.Sprintf("unexpected sheet '%s'", sheet))
+		}
+	}
+
+	for sheet, expectedRows := range expected {
+		actualRows, ok := actual[sheet]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("missing sheet '%s'", sheet))
+			continue
+		}
+
+		for row := 0; row < len(expectedRows) || row < len(actualRows); row++ {
+			var e, a []string
+			if row < len(expectedRows) {
+				e = expectedRows[row]
+			}
+			if row < len(actualRows) {
+				a = actualRows[row]
+			}
+
+			for col := 0; col < len(e) || col < len(a); col++ {
+				var ev, av string
+				if col < len(e) {
+					ev = e[col]
+				}
+				if col < len(a) {
+					av = a[col]
+				}
+				if ev != av {
+					cell, _ := excelize.CoordinatesToCellName(col+1, row+1)
+					diffs = append(diffs, fmt.Sprintf("%s!%s: expected %q, actual %q", sheet, cell, ev, av))
+				}
+			}
+		}
+	}
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("Cells of '%s' differ from '%s':\n  %s", path, goldenPath, strings.Join(diffs, "\n  "))
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package apitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InfluxPoint is one value of sensor series, nil value represents interval
+// without measurements (empty bucket of GROUP BY time())
+type InfluxPoint struct {
+	Time  time.Time
+	Value *float64
+}
+
+// Value returns pointer to value of InfluxPoint
+func Value(value float64) *float64 {
+	return &value
+}
+
+// InfluxQuery is query received by InfluxServer
+type InfluxQuery struct {
+	Database   string
+	Command    string
+	Parameters map[string]interface{}
+}
+
+// InfluxServer is fake of InfluxDB v1 server, it speaks enough of /query
+// protocol to serve statements used by commands (SHOW DATABASES, CREATE
+// DATABASE and SELECT of sensor values). Points of sensor series are served
+// as seeded, they are only limited by time interval of query.
+type InfluxServer struct {
+	*httptest.Server
+
+	// credentials required by server, authentication is not checked if User
+	// is empty
+	User     string
+	Password string
+
+	mu      sync.Mutex
+	series  map[string]map[string][]InfluxPoint
+	queries []InfluxQuery
+}
+
+// NewInfluxServer starts fake InfluxDB server without databases, server
+// should be closed by Close
+func NewInfluxServer() *InfluxServer {
+	s := &InfluxServer{
+		series: map[string]map[string][]InfluxPoint{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/query", s.handleQuery)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func (s *InfluxServer) AddDatabase(db string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.series[db]; !ok {
+		s.series[db] = map[string][]InfluxPoint{}
+	}
+}
+
+// AddSensorValues seeds points of sensor (thing id) series, database is
+// created if it does not exist
+func (s *InfluxServer) AddSensorValues(db, thingId string, points ...InfluxPoint) {
+	s.AddDatabase(db)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.series[db][thingId] = append(s.series[db][thingId], points...)
+}
+
+// Databases returns sorted names of databases
+func (s *InfluxServer) Databases() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.databases()
+}
+
+// Queries returns queries received by server
+func (s *InfluxServer) Queries() []InfluxQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]InfluxQuery{}, s.queries...)
+}
+
+func (s *InfluxServer) databases() []string {
+	result := []string{}
+	for db := range s.series {
+		result = append(result, db)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// influxResult is result of one statement in /query response
+type influxResult struct {
+	StatementId int            `json:"statement_id"`
+	Series      []influxSeries `json:"series,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+type influxSeries struct {
+	Name    string          `json:"name"`
+	Columns []string        `json:"columns"`
+	Values  [][]interface{} `json:"values"`
+}
+
+func (s *InfluxServer) handleQuery(w http.ResponseWriter, r *http.Request) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.User != "" {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != s.User || password != s.Password {
+			writeJson(w, http.StatusUnauthorized, map[string]string{"error": "authorization failed"})
+			return
+		}
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	query := InfluxQuery{
+		Database:   r.Form.Get("db"),
+		Command:    r.Form.Get("q"),
+		Parameters: map[string]interface{}{},
+	}
+
+	if params := r.Form.Get("params"); params != "" {
+		err = json.Unmarshal([]byte(params), &query.Parameters)
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": "failed to parse query parameters: " + err.Error()})
+			return
+		}
+	}
+
+	s.queries = append(s.queries, query)
+
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"results": []influxResult{s.execute(query)},
+	})
+}
+
+func (s *InfluxServer) execute(query InfluxQuery) influxResult {
+
+	command := strings.TrimSpace(query.Command)
+	keyword := strings.ToUpper(command)
+
+	switch {
+
+	case keyword == "SHOW DATABASES":
+		series := influxSeries{Name: "databases", Columns: []string{"name"}}
+		for _, db := range s.databases() {
+			series.Values = append(series.Values, []interface{}{db})
+		}
+		return influxResult{Series: []influxSeries{series}}
+
+	case strings.HasPrefix(keyword, "CREATE DATABASE "):
+		name := unquoteInfluxIdent(strings.TrimSpace(command[len("CREATE DATABASE "):]))
+		if _, ok := s.series[name]; !ok {
+			s.series[name] = map[string][]InfluxPoint{}
+		}
+		return influxResult{}
+
+	case strings.HasPrefix(keyword, "SELECT "):
+		return s.selectSensorValues(query)
+	}
+
+	return influxResult{Error: "statement is not supported by fake server: " + command}
+}
+
+// selectSensorValues serves query of sensor values with thing id given by
+// "id" parameter, time interval by "from" and "to" parameters and number of
+// values by "limit" parameter. Nil points are served only to queries grouped
+// by time.
+func (s *InfluxServer) selectSensorValues(query InfluxQuery) influxResult {
+
+	series, ok := s.series[query.Database]
+	if !ok {
+		return influxResult{Error: "database not found: " + query.Database}
+	}
+
+	id, _ := query.Parameters["id"].(string)
+
+	from, to := time.Time{}, time.Time{}
+	if value, ok := query.Parameters["from"].(string); ok {
+		from, _ = time.Parse(time.RFC3339, value)
+	}
+	if value, ok := query.Parameters["to"].(string); ok {
+		to, _ = time.Parse(time.RFC3339, value)
+	}
+
+	keyword := strings.ToUpper(query.Command)
+	grouped := strings.Contains(keyword, "GROUP BY")
+
+	var values [][]interface{}
+	for _, point := range series[id] {
+		if point.Time.Before(from) || (!to.IsZero() && point.Time.After(to)) {
+			continue
+		}
+		if point.Value == nil && !grouped {
+			continue
+		}
+
+		var value interface{}
+		if point.Value != nil {
+			value = *point.Value
+		}
+		values = append(values, []interface{}{point.Time.UTC().Format(time.RFC3339), value})
+	}
+
+	if strings.Contains(keyword, "ORDER BY TIME DESC") {
+		for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+
+	// json numbers of parameters are decoded as float64
+	if limit, ok := query.Parameters["limit"].(float64); ok && int(limit) < len(values) {
+		values = values[:int(limit)]
+	}
+
+	// InfluxDB returns no series if there are no points
+	if len(values) == 0 {
+		return influxResult{}
+	}
+
+	column := "value"
+	if grouped {
+		column = "mean"
+	}
+
+	return influxResult{Series: []influxSeries{{
+		Name:    "sensor",
+		Columns: []string{"time", column},
+		Values:  values,
+	}}}
+}
+
+func unquoteInfluxIdent(name string) string {
+	if len(name) < 2 || name[0] != '"' || name[len(name)-1] != '"' {
+		return name
+	}
+	replacer := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n")
+	return replacer.Replace(name[1 : len(name)-1])
+}
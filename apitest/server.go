@@ -0,0 +1,721 @@
+// Package apitest provides in-memory fake of PIOT server for testing of
+// commands. Server speaks enough of PIOT GraphQL API to serve operations of
+// api.PiotAPI, state (things, orgs, users) is seeded by Add* methods.
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+
+	"piot-cli/api"
+
+	"github.com/op/go-logging"
+)
+
+const (
+	DEFAULT_USER     = "admin@example.com"
+	DEFAULT_PASSWORD = "secret"
+	DEFAULT_TOKEN    = "fake-token"
+)
+
+// Request is GraphQL request received by server
+type Request struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type user struct {
+	api.User
+	password string
+}
+
+type Server struct {
+	*httptest.Server
+
+	// credentials of logged user, user is created by NewServer
+	User     string
+	Password string
+	Token    string
+
+	mu        sync.Mutex
+	lastId    int
+	things    []api.Thing
+	parents   map[string]string
+	orgs      []api.Org
+	users     []*user
+	members   map[string][]string
+	activeOrg string
+	requests  []Request
+	failures  map[string]*gqlError
+}
+
+// NewServer starts fake server with admin user (DEFAULT_USER) and no other
+// data, server should be closed by Close
+func NewServer() *Server {
+	s := &Server{
+		User:     DEFAULT_USER,
+		Password: DEFAULT_PASSWORD,
+		Token:    DEFAULT_TOKEN,
+		parents:  map[string]string{},
+		members:  map[string][]string{},
+		failures: map[string]*gqlError{},
+	}
+
+	s.AddUser(api.User{Email: s.User, IsAdmin: true}, s.Password)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", s.handleLogin)
+	mux.HandleFunc("/query", s.handleQuery)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// NewClient returns api client connected to server as logged user, token
+// cache of user running tests is not touched
+func (s *Server) NewClient(logger *logging.Logger) *api.Client {
+	return api.NewClientWithConfig(logger, api.ClientConfig{
+		Url:          s.URL,
+		User:         s.User,
+		Password:     s.Password,
+		NoTokenCache: true,
+	})
+}
+
+func (s *Server) newId(prefix string) string {
+	s.lastId++
+	return fmt.Sprintf("%s%d", prefix, s.lastId)
+}
+
+// AddOrg seeds organization, logged user becomes its member and first
+// organization becomes active one. Id is generated if empty.
+func (s *Server) AddOrg(org api.Org) api.Org {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if org.Id == "" {
+		org.Id = s.newId("org")
+	}
+	s.orgs = append(s.orgs, org)
+	s.members[org.Id] = append(s.members[org.Id], s.loggedUser().Id)
+
+	if s.activeOrg == "" {
+		s.activeOrg = org.Id
+	}
+
+	return org
+}
+
+// AddThing seeds thing, thing belongs to active organization if OrgId is
+// empty. Id is generated if empty.
+func (s *Server) AddThing(thing api.Thing) api.Thing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if thing.Id == "" {
+		thing.Id = s.newId("thing")
+	}
+	if thing.OrgId == "" {
+		thing.OrgId = s.activeOrg
+	}
+	s.things = append(s.things, thing)
+
+	return thing
+}
+
+// SetThingParent sets parent of thing (e.g. device of sensor)
+func (s *Server) SetThingParent(id, parentId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.parents[id] = parentId
+}
+
+// AddUser seeds user, Orgs of user are ignored (see AddOrgMember). Id is
+// generated if empty.
+func (s *Server) AddUser(u api.User, password string) api.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.Id == "" {
+		u.Id = s.newId("user")
+	}
+	u.Orgs = nil
+	s.users = append(s.users, &user{User: u, password: password})
+
+	return u
+}
+
+func (s *Server) AddOrgMember(orgId, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.members[orgId] = append(s.members[orgId], userId)
+}
+
+// RemoveOrgMember removes user from members of organization (e.g. logged
+// user from seeded organization)
+func (s *Server) RemoveOrgMember(orgId, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var members []string
+	for _, id := range s.members[orgId] {
+		if id != userId {
+			members = append(members, id)
+		}
+	}
+	s.members[orgId] = members
+}
+
+// Fail makes all following operations with given root field (e.g.
+// updateThing) fail with GraphQL error of given code
+func (s *Server) Fail(field, code, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[field] = newGqlError(code, "%s", message)
+}
+
+// SetAdmin sets admin flag of logged user, user created by NewServer is admin
+func (s *Server) SetAdmin(isAdmin bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loggedUser().IsAdmin = isAdmin
+}
+
+// SetActiveOrg sets active organization of logged user
+func (s *Server) SetActiveOrg(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.activeOrg = id
+}
+
+// Things returns current state of things (e.g. after mutations)
+func (s *Server) Things() []api.Thing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]api.Thing{}, s.things...)
+}
+
+// Orgs returns current state of organizations
+func (s *Server) Orgs() []api.Org {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]api.Org{}, s.orgs...)
+}
+
+// Users returns current state of users including their organizations
+func (s *Server) Users() []api.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []api.User
+	for _, u := range s.users {
+		result = append(result, s.userData(u))
+	}
+	return result
+}
+
+// Requests returns GraphQL requests received by server
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request{}, s.requests...)
+}
+
+func (s *Server) loggedUser() *user {
+	return s.findUser(func(u *user) bool { return u.Email == s.User })
+}
+
+func (s *Server) findUser(match func(u *user) bool) *user {
+	for _, u := range s.users {
+		if match(u) {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Server) findThing(id string) int {
+	for i := range s.things {
+		if s.things[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) findOrg(id string) int {
+	for i := range s.orgs {
+		if s.orgs[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) isMember(orgId, userId string) bool {
+	for _, id := range s.members[orgId] {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// userData returns user together with organizations the user is member of
+func (s *Server) userData(u *user) api.User {
+	result := u.User
+	result.Orgs = []api.Org{}
+	for _, org := range s.orgs {
+		if s.isMember(org.Id, u.Id) {
+			result.Orgs = append(result.Orgs, org)
+		}
+	}
+	return result
+}
+
+func (s *Server) profile() api.UserProfile {
+	u := s.userData(s.loggedUser())
+	return api.UserProfile{
+		Email:   u.Email,
+		IsAdmin: u.IsAdmin,
+		OrgId:   s.activeOrg,
+		Orgs:    u.Orgs,
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+
+	var credentials struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&credentials)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if credentials.Email != s.User || credentials.Password != s.Password {
+		http.Error(w, "Wrong credentials", http.StatusUnauthorized)
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]string{"token": s.Token})
+}
+
+// name of root field of GraphQL operation, client sends one field per request
+var gqlRootField = regexp.MustCompile(`^\s*(?:(?:query|mutation)\s*(?:\([^)]*\))?\s*)?\{\s*(\w+)`)
+
+var gqlFieldName = regexp.MustCompile(`\w+`)
+
+// selectedFields returns names of fields selected by rest of query following
+// root field, arguments of root field are skipped. Names of nested fields are
+// not distinguished, it is enough for queries sent by api.Client.
+func selectedFields(query string) map[string]bool {
+
+	result := map[string]bool{}
+
+	i := strings.Index(query, "{")
+	if i < 0 {
+		return result
+	}
+
+	for _, name := range gqlFieldName.FindAllString(query[i:], -1) {
+		result[name] = true
+	}
+
+	return result
+}
+
+// gqlError is error reported inside of successful response
+type gqlError struct {
+	code    string
+	message string
+}
+
+func newGqlError(code, format string, args ...interface{}) *gqlError {
+	return &gqlError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != s.User || password != s.Password {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var req Request
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.requests = append(s.requests, req)
+
+	match := gqlRootField.FindStringSubmatch(req.Query)
+	if match == nil {
+		s.writeGqlError(w, newGqlError("GRAPHQL_PARSE_FAILED", "Cannot parse query"))
+		return
+	}
+
+	field := match[1]
+
+	if e, ok := s.failures[field]; ok {
+		s.writeGqlError(w, e)
+		return
+	}
+
+	result, gqlErr := s.resolve(field, selectedFields(req.Query[len(match[0]):]), req.Variables)
+	if gqlErr != nil {
+		s.writeGqlError(w, gqlErr)
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{field: result},
+	})
+}
+
+func (s *Server) writeGqlError(w http.ResponseWriter, e *gqlError) {
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"data": nil,
+		"errors": []api.GqlError{{
+			Message:    e.message,
+			Extensions: map[string]interface{}{"code": e.code},
+		}},
+	})
+}
+
+// helpers for reading of variables, json numbers are decoded as float64
+
+func stringVar(vars map[string]interface{}, name string) string {
+	value, _ := vars[name].(string)
+	return value
+}
+
+func objectVar(vars map[string]interface{}, name string) map[string]interface{} {
+	value, _ := vars[name].(map[string]interface{})
+	return value
+}
+
+func (s *Server) resolve(field string, fields map[string]bool, vars map[string]interface{}) (interface{}, *gqlError) {
+
+	switch field {
+
+	// things
+
+	case "things":
+		all, _ := vars["all"].(bool)
+		orgId, scoped := vars["org_id"].(string)
+		u := s.loggedUser()
+		if all && !u.IsAdmin {
+			return nil, newGqlError("FORBIDDEN", "Things of all organizations are available to admin only")
+		}
+		if scoped && !u.IsAdmin && !s.isMember(orgId, u.Id) {
+			return nil, newGqlError("FORBIDDEN", "User is not member of organization '%s'", orgId)
+		}
+		result := []map[string]interface{}{}
+		for _, thing := range s.things {
+			if scoped && thing.OrgId == orgId || !scoped && (all || thing.OrgId == s.activeOrg) {
+				result = append(result, s.thingData(thing, fields))
+			}
+		}
+		return result, nil
+
+	case "thing":
+		i := s.findThing(stringVar(vars, "id"))
+		if i < 0 {
+			return nil, nil
+		}
+		return s.thingData(s.things[i], fields), nil
+
+	case "createThing":
+		thing := api.Thing{
+			Id:               s.newId("thing"),
+			Name:             stringVar(vars, "name"),
+			Type:             stringVar(vars, "type"),
+			Enabled:          true,
+			LastSeenInterval: 300,
+			OrgId:            s.activeOrg,
+		}
+		if thing.Name == "" || thing.Type == "" {
+			return nil, newGqlError("BAD_USER_INPUT", "Name and type of thing are mandatory")
+		}
+		s.things = append(s.things, thing)
+		return s.thingData(thing, fields), nil
+
+	case "updateThing", "updateThingSensorData":
+		input := objectVar(vars, "thing")
+		if field == "updateThingSensorData" {
+			input = objectVar(vars, "data")
+		}
+		i := s.findThing(stringVar(input, "id"))
+		if i < 0 {
+			return nil, newGqlError("NOT_FOUND", "Thing '%s' does not exist", stringVar(input, "id"))
+		}
+		if field == "updateThingSensorData" {
+			input = map[string]interface{}{"sensor": input}
+		}
+		if err := update(&s.things[i], input); err != nil {
+			return nil, newGqlError("BAD_USER_INPUT", "%v", err)
+		}
+		return true, nil
+
+	case "deleteThing":
+		i := s.findThing(stringVar(vars, "id"))
+		if i < 0 {
+			return nil, newGqlError("NOT_FOUND", "Thing '%s' does not exist", stringVar(vars, "id"))
+		}
+		s.things = append(s.things[:i], s.things[i+1:]...)
+		return true, nil
+
+	// organizations
+
+	case "orgs":
+		// users which are not admins see their organizations only
+		u := s.loggedUser()
+		result := []api.Org{}
+		for _, org := range s.orgs {
+			if u.IsAdmin || s.isMember(org.Id, u.Id) {
+				result = append(result, org)
+			}
+		}
+		return result, nil
+
+	case "org":
+		i := s.findOrg(stringVar(vars, "id"))
+		if i < 0 {
+			return nil, nil
+		}
+		users := []api.User{}
+		for _, id := range s.members[s.orgs[i].Id] {
+			if u := s.findUser(func(u *user) bool { return u.Id == id }); u != nil {
+				users = append(users, s.userData(u))
+			}
+		}
+		return map[string]interface{}{
+			"id":          s.orgs[i].Id,
+			"name":        s.orgs[i].Name,
+			"description": s.orgs[i].Description,
+			"influxdb":    s.orgs[i].InfluxDb,
+			"users":       users,
+		}, nil
+
+	case "createOrg":
+		org := api.Org{Id: s.newId("org")}
+		if err := update(&org, objectVar(vars, "org")); err != nil {
+			return nil, newGqlError("BAD_USER_INPUT", "%v", err)
+		}
+		if org.Name == "" {
+			return nil, newGqlError("BAD_USER_INPUT", "Name of organization is mandatory")
+		}
+		s.orgs = append(s.orgs, org)
+		return org, nil
+
+	case "updateOrg":
+		input := objectVar(vars, "org")
+		i := s.findOrg(stringVar(input, "id"))
+		if i < 0 {
+			return nil, newGqlError("NOT_FOUND", "Organization '%s' does not exist", stringVar(input, "id"))
+		}
+		if err := update(&s.orgs[i], input); err != nil {
+			return nil, newGqlError("BAD_USER_INPUT", "%v", err)
+		}
+		return true, nil
+
+	case "deleteOrg":
+		id := stringVar(vars, "id")
+		i := s.findOrg(id)
+		if i < 0 {
+			return nil, newGqlError("NOT_FOUND", "Organization '%s' does not exist", id)
+		}
+		s.orgs = append(s.orgs[:i], s.orgs[i+1:]...)
+		delete(s.members, id)
+		return true, nil
+
+	case "addOrgUser", "removeOrgUser":
+		orgId, userId := stringVar(vars, "org_id"), stringVar(vars, "user_id")
+		if s.findOrg(orgId) < 0 {
+			return nil, newGqlError("NOT_FOUND", "Organization '%s' does not exist", orgId)
+		}
+		if s.findUser(func(u *user) bool { return u.Id == userId }) == nil {
+			return nil, newGqlError("NOT_FOUND", "User '%s' does not exist", userId)
+		}
+		var members []string
+		for _, id := range s.members[orgId] {
+			if id != userId {
+				members = append(members, id)
+			}
+		}
+		if field == "addOrgUser" {
+			members = append(members, userId)
+		}
+		s.members[orgId] = members
+		return true, nil
+
+	// users
+
+	case "users":
+		result := []api.User{}
+		for _, u := range s.users {
+			result = append(result, s.userData(u))
+		}
+		return result, nil
+
+	case "createUser":
+		input := objectVar(vars, "user")
+		u := &user{User: api.User{Id: s.newId("user")}, password: stringVar(input, "password")}
+		if err := update(&u.User, input); err != nil {
+			return nil, newGqlError("BAD_USER_INPUT", "%v", err)
+		}
+		if u.Email == "" || s.findUser(func(e *user) bool { return e.Email == u.Email }) != nil {
+			return nil, newGqlError("BAD_USER_INPUT", "Email is empty or already used")
+		}
+		s.users = append(s.users, u)
+		return s.userData(u), nil
+
+	case "updateUser":
+		input := objectVar(vars, "user")
+		u := s.findUser(func(u *user) bool { return u.Id == stringVar(input, "id") })
+		if u == nil {
+			return nil, newGqlError("NOT_FOUND", "User '%s' does not exist", stringVar(input, "id"))
+		}
+		if err := update(&u.User, input); err != nil {
+			return nil, newGqlError("BAD_USER_INPUT", "%v", err)
+		}
+		if password, ok := input["password"].(string); ok {
+			u.password = password
+		}
+		return true, nil
+
+	case "deleteUser":
+		id := stringVar(vars, "id")
+		for i, u := range s.users {
+			if u.Id == id {
+				s.users = append(s.users[:i], s.users[i+1:]...)
+				return true, nil
+			}
+		}
+		return nil, newGqlError("NOT_FOUND", "User '%s' does not exist", id)
+
+	// profile of logged user
+
+	case "userProfile":
+		return s.profile(), nil
+
+	case "updateUserProfile":
+		input := objectVar(vars, "profile")
+		if orgId, ok := input["org_id"].(string); ok {
+			if !s.isMember(orgId, s.loggedUser().Id) {
+				return nil, newGqlError("FORBIDDEN", "User is not member of organization '%s'", orgId)
+			}
+			s.activeOrg = orgId
+		}
+		if email, ok := input["email"].(string); ok {
+			s.loggedUser().Email = email
+			s.User = email
+		}
+		return s.profile(), nil
+
+	case "updateUserPassword":
+		input := objectVar(vars, "password")
+		if stringVar(input, "password") != s.Password {
+			return nil, newGqlError("BAD_USER_INPUT", "Current password does not match")
+		}
+		s.Password = stringVar(input, "new_password")
+		s.loggedUser().password = s.Password
+		return true, nil
+	}
+
+	return nil, newGqlError("GRAPHQL_VALIDATION_FAILED", "Cannot query field '%s'", field)
+}
+
+// thingData returns thing in form of GraphQL object including its parent,
+// only fields selected by query are returned (e.g. org_id)
+func (s *Server) thingData(thing api.Thing, fields map[string]bool) map[string]interface{} {
+
+	var result map[string]interface{}
+	data, _ := json.Marshal(thing)
+	json.Unmarshal(data, &result)
+
+	result["parent"] = nil
+	if parentId, ok := s.parents[thing.Id]; ok {
+		result["parent"] = map[string]interface{}{"id": parentId}
+	}
+
+	for name := range result {
+		if !fields[name] {
+			delete(result, name)
+		}
+	}
+
+	return result
+}
+
+// update applies input object to json representation of target, fields
+// which are not part of input keep their values
+func update(target interface{}, input map[string]interface{}) error {
+
+	current, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal(current, &fields)
+	if err != nil {
+		return err
+	}
+
+	merge(fields, input)
+
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(merged, target)
+}
+
+func merge(target, input map[string]interface{}) {
+
+	for key := range input {
+		nested, isMap := input[key].(map[string]interface{})
+		existing, hasMap := target[key].(map[string]interface{})
+		if isMap && hasMap {
+			merge(existing, nested)
+			continue
+		}
+		target[key] = input[key]
+	}
+}
@@ -3,9 +3,7 @@ package cmd
 import (
 	"fmt"
 
-	influx "github.com/influxdata/influxdb1-client/v2"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var adminCmd = &cobra.Command{
@@ -18,34 +16,19 @@ var adminInfluxDb = &cobra.Command{
 	Use:   "influxdb",
 	Short: "Administration of InfluxDb",
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
 
 		var err error
 
-		ic, err := influx.NewHTTPClient(influx.HTTPConfig{
-			Addr:     viper.GetString("influxdb.url"),
-			Username: viper.GetString("influxdb.user"),
-			Password: viper.GetString("influxdb.password"),
-		})
+		ic, err := newInfluxClient()
 		handleError(err)
 		defer ic.Close()
 
-		query := "SHOW DATABASES"
-
-		log.Debugf("query: %s", query)
-
-		q := influx.NewQuery(query, "", "")
-
-		response, err := ic.Query(q)
+		names, err := listInfluxDbs(ctx, ic)
 		handleError(err)
 
-		if response.Error() != nil {
-			handleError(response.Error())
-		}
-
-		log.Debugf("response: %s", response)
-
-		for _, db_name := range response.Results[0].Series[0].Values {
-			fmt.Printf("%v\n", db_name[0])
+		for _, name := range names {
+			fmt.Printf("%s\n", name)
 		}
 	},
 }
@@ -55,31 +38,16 @@ var adminInfluxDbCreate = &cobra.Command{
 	Short: "Create database",
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
 
 		var err error
 
-		ic, err := influx.NewHTTPClient(influx.HTTPConfig{
-			Addr:     viper.GetString("influxdb.url"),
-			Username: viper.GetString("influxdb.user"),
-			Password: viper.GetString("influxdb.password"),
-		})
+		ic, err := newInfluxClient()
 		handleError(err)
 		defer ic.Close()
 
-		query := fmt.Sprintf("CREATE DATABASE \"%s\"", args[0])
-
-		log.Debugf("query: %s", query)
-
-		q := influx.NewQuery(query, "", "")
-
-		response, err := ic.Query(q)
+		err = createInfluxDb(ctx, ic, args[0])
 		handleError(err)
-
-		if response.Error() != nil {
-			handleError(response.Error())
-		}
-
-		log.Debugf("response: %s", response)
 	},
 }
 
@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"piot-cli/api"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	config_user_admin             bool
+	config_user_revoke            bool
+	config_user_generate_password bool
+	config_user_orgs              string
+)
+
+func getUserByEmailOrFail(ctx context.Context, client api.PiotAPI, email string) (*api.User, error) {
+
+	user, err := client.GetUserByEmailContext(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("User '%s' does not exist", email)
+	}
+
+	return user, nil
+}
+
+// formatUserOrgs returns sorted comma separated names of user organizations
+func formatUserOrgs(user *api.User) string {
+
+	var names []string
+	for _, org := range user.Orgs {
+		names = append(names, org.Name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+// getNewUserPassword generates random password if --generate-password flag is
+// set, otherwise password is read from terminal
+func getNewUserPassword() (string, error) {
+
+	if config_user_generate_password {
+		return generatePassword()
+	}
+
+	return askForNewPassword()
+}
+
+var adminUserCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Administration of users",
+	Long:  ``,
+}
+
+// runAdminUserList prints table of all users
+func runAdminUserList(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	users, err := client.GetUsersContext(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+
+	w := tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	fmt.Fprintf(w, "EMAIL\tADMIN\tORGS\tID\t\n")
+	for i := 0; i < len(users); i++ {
+		fmt.Fprintf(w, "%s\t%t\t%s\t%s\t\n",
+			users[i].Email,
+			users[i].IsAdmin,
+			formatUserOrgs(&users[i]),
+			users[i].Id,
+		)
+	}
+	return w.Flush()
+}
+
+var adminUserListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runAdminUserList(cmd.Context(), newApiClient(), cmd.OutOrStdout())
+		handleError(err)
+	},
+}
+
+// runAdminUserShow prints details of user and organizations of user
+func runAdminUserShow(ctx context.Context, client api.PiotAPI, out io.Writer, email string) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUserByEmailOrFail(ctx, client, email)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	fmt.Fprintf(w, "Id:\t%s\n", user.Id)
+	fmt.Fprintf(w, "Email:\t%s\n", user.Email)
+	fmt.Fprintf(w, "Admin:\t%t\n", user.IsAdmin)
+	w.Flush()
+
+	fmt.Fprintln(out)
+
+	w = tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	fmt.Fprintf(w, "ORG\tID\t\n")
+	for _, org := range user.Orgs {
+		fmt.Fprintf(w, "%s\t%s\t\n", org.Name, org.Id)
+	}
+	return w.Flush()
+}
+
+var adminUserShowCmd = &cobra.Command{
+	Use:   "show EMAIL",
+	Short: "Show user details",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runAdminUserShow(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args[0])
+		handleError(err)
+	},
+}
+
+// runAdminUserCreate creates user and adds it to organizations given by
+// --orgs flag
+func runAdminUserCreate(ctx context.Context, client api.PiotAPI, out io.Writer, email string) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := client.GetUserByEmailContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("User '%s' already exists", email)
+	}
+
+	// resolve organizations first to avoid partially onboarded user
+	var orgs []*api.Org
+	if config_user_orgs != "" {
+		for _, name := range strings.Split(config_user_orgs, ",") {
+			org, err := getOrgByNameOrFail(ctx, client, strings.TrimSpace(name))
+			if err != nil {
+				return err
+			}
+			orgs = append(orgs, org)
+		}
+	}
+
+	password, err := getNewUserPassword()
+	if err != nil {
+		return err
+	}
+
+	user, err := client.CreateUserContext(ctx, &api.UserAttributes{
+		Email:    &email,
+		Password: &password,
+		IsAdmin:  &config_user_admin,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "User '%s' created (%s)\n", user.Email, user.Id)
+
+	for _, org := range orgs {
+		err = client.AddOrgMemberContext(ctx, org.Id, user.Id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "User '%s' added to organization '%s'\n", user.Email, org.Name)
+	}
+
+	if config_user_generate_password {
+		fmt.Fprintf(out, "Password: %s\n", password)
+	}
+
+	return nil
+}
+
+var adminUserCreateCmd = &cobra.Command{
+	Use:   "create EMAIL",
+	Short: "Create user",
+	Long: `Create user with password read from terminal (or generated by
+--generate-password flag) and optionally add it to organizations.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runAdminUserCreate(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args[0])
+		handleError(err)
+	},
+}
+
+// runAdminUserDelete deletes users identified by emails, each deletion is
+// confirmed by user unless --yes flag is set
+func runAdminUserDelete(ctx context.Context, client api.PiotAPI, out io.Writer, emails []string) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, email := range emails {
+		user, err := getUserByEmailOrFail(ctx, client, email)
+		if err != nil {
+			return err
+		}
+
+		if !config_yes && !askForConfirmation(out, fmt.Sprintf("Delete user '%s' (%s)?", user.Email, user.Id)) {
+			continue
+		}
+
+		err = client.DeleteUserContext(ctx, user.Id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "User '%s' deleted\n", user.Email)
+	}
+
+	return nil
+}
+
+var adminUserDeleteCmd = &cobra.Command{
+	Use:   "delete EMAIL...",
+	Short: "Delete users",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runAdminUserDelete(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args)
+		handleError(err)
+	},
+}
+
+// runAdminUserSetAdmin grants or revokes (--revoke flag) admin privileges
+func runAdminUserSetAdmin(ctx context.Context, client api.PiotAPI, out io.Writer, email string) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUserByEmailOrFail(ctx, client, email)
+	if err != nil {
+		return err
+	}
+
+	is_admin := !config_user_revoke
+
+	user, err = client.UpdateUserContext(ctx, user.Id, &api.UserAttributes{IsAdmin: &is_admin})
+	if err != nil {
+		return err
+	}
+
+	if user.IsAdmin {
+		fmt.Fprintf(out, "User '%s' is admin\n", user.Email)
+	} else {
+		fmt.Fprintf(out, "User '%s' is not admin\n", user.Email)
+	}
+
+	return nil
+}
+
+var adminUserSetAdminCmd = &cobra.Command{
+	Use:   "set-admin EMAIL",
+	Short: "Grant (or revoke with --revoke) admin privileges",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runAdminUserSetAdmin(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args[0])
+		handleError(err)
+	},
+}
+
+// runAdminUserResetPassword sets new password of user
+func runAdminUserResetPassword(ctx context.Context, client api.PiotAPI, out io.Writer, email string) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUserByEmailOrFail(ctx, client, email)
+	if err != nil {
+		return err
+	}
+
+	password, err := getNewUserPassword()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.UpdateUserContext(ctx, user.Id, &api.UserAttributes{Password: &password})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Password of user '%s' changed\n", user.Email)
+
+	if config_user_generate_password {
+		fmt.Fprintf(out, "Password: %s\n", password)
+	}
+
+	return nil
+}
+
+var adminUserResetPasswordCmd = &cobra.Command{
+	Use:   "reset-password EMAIL",
+	Short: "Set new password of user",
+	Long: `Set new password of user, password is read from terminal or generated by
+--generate-password flag.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runAdminUserResetPassword(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args[0])
+		handleError(err)
+	},
+}
+
+func init() {
+	adminCmd.AddCommand(adminUserCmd)
+
+	adminUserCmd.AddCommand(adminUserListCmd)
+	adminUserCmd.AddCommand(adminUserShowCmd)
+
+	adminUserCmd.AddCommand(adminUserCreateCmd)
+	adminUserCreateCmd.Flags().BoolVar(&config_user_admin, "admin", false, "grant admin privileges")
+	adminUserCreateCmd.Flags().StringVar(&config_user_orgs, "orgs", "", "comma separated list of organizations user will be member of")
+	adminUserCreateCmd.Flags().BoolVar(&config_user_generate_password, "generate-password", false, "generate random password and print it")
+
+	adminUserCmd.AddCommand(adminUserDeleteCmd)
+	adminUserDeleteCmd.Flags().BoolVarP(&config_yes, "yes", "y", false, "do not ask for confirmation")
+
+	adminUserCmd.AddCommand(adminUserSetAdminCmd)
+	adminUserSetAdminCmd.Flags().BoolVar(&config_user_revoke, "revoke", false, "revoke admin privileges")
+
+	adminUserCmd.AddCommand(adminUserResetPasswordCmd)
+	adminUserResetPasswordCmd.Flags().BoolVar(&config_user_generate_password, "generate-password", false, "generate random password and print it")
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"piot-cli/api"
+	"piot-cli/apitest"
+)
+
+// listUsersAfter runs command and prints listing of users then
+func listUsersAfter(run func(ctx context.Context, client api.PiotAPI, out io.Writer) error) func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+	return func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+		if err := run(ctx, client, out); err != nil {
+			return err
+		}
+		return runAdminUserList(ctx, client, out)
+	}
+}
+
+// withoutPassword runs command printing generated password, password is
+// checked and replaced by placeholder to keep output stable
+func withoutPassword(run func(ctx context.Context, client api.PiotAPI, out io.Writer) error) func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+	return func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+		var buf bytes.Buffer
+		if err := run(ctx, client, &buf); err != nil {
+			return err
+		}
+
+		for _, line := range strings.SplitAfter(buf.String(), "\n") {
+			if strings.HasPrefix(line, "Password: ") {
+				password := strings.TrimSpace(strings.TrimPrefix(line, "Password: "))
+				if len(password) != PASSWORD_LENGTH {
+					return fmt.Errorf("Unexpected generated password '%s'", password)
+				}
+				line = "Password: GENERATED\n"
+			}
+			io.WriteString(out, line)
+		}
+
+		return nil
+	}
+}
+
+func TestAdminUserCommands(t *testing.T) {
+
+	runCommandCases(t, seedOrgs, []commandCase{
+		{
+			name: "admin-user-list",
+			run:  runAdminUserList,
+		},
+		{
+			name: "admin-user-show",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runAdminUserShow(ctx, client, out, "admin@example.com")
+			},
+		},
+		{
+			name: "admin-user-show-missing",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runAdminUserShow(ctx, client, out, "missing@example.com")
+			},
+			err: "User 'missing@example.com' does not exist",
+		},
+		{
+			name: "admin-user-create",
+			setup: func(*apitest.Server) {
+				config_user_orgs = "HOME, CUSTOMER"
+				config_user_admin = true
+				config_user_generate_password = true
+			},
+			run: listUsersAfter(withoutPassword(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runAdminUserCreate(ctx, client, out, "carol@example.com")
+			})),
+		},
+		{
+			name:  "admin-user-create-existing",
+			setup: func(*apitest.Server) { config_user_generate_password = true },
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runAdminUserCreate(ctx, client, out, "alice@example.com")
+			},
+			err: "User 'alice@example.com' already exists",
+		},
+		{
+			name: "admin-user-create-missing-org",
+			setup: func(*apitest.Server) {
+				config_user_orgs = "HOME,MISSING"
+				config_user_generate_password = true
+			},
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runAdminUserCreate(ctx, client, out, "carol@example.com")
+			},
+			err: "Organization 'MISSING' does not exist",
+		},
+		{
+			name:  "admin-user-delete",
+			setup: func(*apitest.Server) { config_yes = true },
+			run: listUsersAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runAdminUserDelete(ctx, client, out, []string{"alice@example.com", "bob@example.com"})
+			}),
+		},
+		{
+			name: "admin-user-set-admin",
+			run: listUsersAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runAdminUserSetAdmin(ctx, client, out, "bob@example.com")
+			}),
+		},
+		{
+			name:  "admin-user-revoke-admin",
+			setup: func(*apitest.Server) { config_user_revoke = true },
+			run: listUsersAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runAdminUserSetAdmin(ctx, client, out, "admin@example.com")
+			}),
+		},
+	})
+}
+
+func TestRunAdminUserResetPassword(t *testing.T) {
+
+	ctx := context.Background()
+	server, client := newTestServer(t)
+	seedOrgs(server)
+
+	config_user_generate_password = true
+
+	var out bytes.Buffer
+	err := runAdminUserResetPassword(ctx, client, &out, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || lines[0] != "Password of user 'alice@example.com' changed" || !strings.HasPrefix(lines[1], "Password: ") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	// generated password is sent to server
+	password := strings.TrimPrefix(lines[1], "Password: ")
+	sent := false
+	for _, request := range server.Requests() {
+		if user, ok := request.Variables["user"].(map[string]interface{}); ok {
+			sent = sent || user["password"] == password
+		}
+	}
+	if !sent {
+		t.Errorf("password '%s' not sent to server", password)
+	}
+}
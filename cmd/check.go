@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"piot-cli/api"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// monitoring plugin states (Nagios/Icinga exit codes)
+const (
+	CHECK_OK       = 0
+	CHECK_WARNING  = 1
+	CHECK_CRITICAL = 2
+	CHECK_UNKNOWN  = 3
+)
+
+var checkStateNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+var (
+	config_check_warning  float64
+	config_check_critical float64
+	config_check_type     string
+	config_check_ignore   string
+	config_check_limits   []string
+)
+
+type valueLimit struct {
+	min float64
+	max float64
+}
+
+// parseValueLimits parses per class value limits in form class=min:max,
+// one of min or max could be omitted (e.g. humidity=:90)
+func parseValueLimits(specs []string) (map[string]valueLimit, error) {
+
+	result := map[string]valueLimit{}
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid limit '%s', expected class=min:max", spec)
+		}
+
+		bounds := strings.SplitN(parts[1], ":", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("Invalid limit '%s', expected class=min:max", spec)
+		}
+
+		limit := valueLimit{min: -1e308, max: 1e308}
+		var err error
+
+		if bounds[0] != "" {
+			limit.min, err = strconv.ParseFloat(bounds[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid minimum in limit '%s': %v", spec, err)
+			}
+		}
+		if bounds[1] != "" {
+			limit.max, err = strconv.ParseFloat(bounds[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid maximum in limit '%s': %v", spec, err)
+			}
+		}
+
+		result[parts[0]] = limit
+	}
+
+	return result, nil
+}
+
+// perfdataLabel returns quoted label of perfdata, single quotes inside of
+// label are doubled as required by monitoring plugin guidelines
+func perfdataLabel(label string) string {
+	return "'" + strings.Replace(label, "'", "''", -1) + "'"
+}
+
+// checkThing evaluates last seen state and value of thing, returns check state
+// and description of problem (empty for OK state)
+func checkThing(thing *api.Thing, limits map[string]valueLimit) (int, string) {
+
+	state := CHECK_OK
+	var problems []string
+
+	if thing.LastSeenInterval > 0 && thing.LastSeen == 0 {
+		state = CHECK_CRITICAL
+		problems = append(problems, "never seen")
+	} else if thing.LastSeenInterval > 0 {
+		age := thingAge(thing)
+		interval := time.Duration(thing.LastSeenInterval) * time.Second
+
+		if age > time.Duration(float64(interval)*config_check_critical) {
+			state = CHECK_CRITICAL
+			problems = append(problems, fmt.Sprintf("not seen for %s", formatAge(age)))
+		} else if age > time.Duration(float64(interval)*config_check_warning) {
+			state = CHECK_WARNING
+			problems = append(problems, fmt.Sprintf("not seen for %s", formatAge(age)))
+		}
+	}
+
+	if limit, ok := limits[thing.Sensor.Class]; ok && thing.Sensor.Value != "" {
+		value, err := strconv.ParseFloat(thing.Sensor.Value, 64)
+		if err != nil {
+			state = CHECK_CRITICAL
+			problems = append(problems, fmt.Sprintf("invalid value '%s'", thing.Sensor.Value))
+		} else if value < limit.min || value > limit.max {
+			state = CHECK_CRITICAL
+			problems = append(problems, fmt.Sprintf("value %s out of range", thing.Sensor.Value))
+		}
+	}
+
+	return state, strings.Join(problems, ", ")
+}
+
+// runCheck evaluates all enabled things and returns check state together with
+// summary line (including perfdata)
+func runCheck(ctx context.Context, client api.PiotAPI) (int, string) {
+
+	limits, err := parseValueLimits(config_check_limits)
+	if err != nil {
+		return CHECK_UNKNOWN, err.Error()
+	}
+
+	var names []string
+	if config_names != "" {
+		names = strings.Split(config_names, ",")
+	}
+
+	var ignore api.ThingFilterFunctionType
+	if config_check_ignore != "" {
+		ignore, err = newThingPatternFilter(strings.Split(config_check_ignore, ","), false)
+		if err != nil {
+			return CHECK_UNKNOWN, err.Error()
+		}
+	}
+
+	err = client.LoginContext(ctx)
+	if err != nil {
+		return CHECK_UNKNOWN, err.Error()
+	}
+
+	things, err := getThings(ctx, client, config_all, func(thing *api.Thing) bool {
+		if !thing.Enabled {
+			return false
+		}
+		if config_check_type != "" && thing.Type != config_check_type {
+			return false
+		}
+		if len(names) > 0 && !contains(names, thing.Name) {
+			return false
+		}
+		if ignore != nil && ignore(thing) {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return CHECK_UNKNOWN, err.Error()
+	}
+
+	state := CHECK_OK
+	counts := make([]int, len(checkStateNames))
+	var problems []string
+	var perfdata []string
+
+	for i := 0; i < len(things); i++ {
+		thing_state, problem := checkThing(&things[i], limits)
+
+		counts[thing_state]++
+		if thing_state > state {
+			state = thing_state
+		}
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("%s %s", things[i].Name, problem))
+		}
+
+		// age of thing which was never seen is unknown
+		if things[i].LastSeenInterval > 0 && things[i].LastSeen > 0 {
+			interval := float64(things[i].LastSeenInterval)
+			perfdata = append(perfdata, fmt.Sprintf("%s=%ds;%.0f;%.0f;0;",
+				perfdataLabel(things[i].Name+"_age"),
+				int64(thingAge(&things[i])/time.Second),
+				interval*config_check_warning,
+				interval*config_check_critical,
+			))
+		}
+	}
+
+	summary := fmt.Sprintf("%d things checked, %d warning, %d critical",
+		len(things), counts[CHECK_WARNING], counts[CHECK_CRITICAL])
+	if len(problems) > 0 {
+		summary += ": " + strings.Join(problems, ", ")
+	}
+
+	perfdata = append([]string{
+		fmt.Sprintf("things=%d;;;0;", len(things)),
+		fmt.Sprintf("warning=%d;;;0;", counts[CHECK_WARNING]),
+		fmt.Sprintf("critical=%d;;;0;", counts[CHECK_CRITICAL]),
+	}, perfdata...)
+
+	return state, summary + " | " + strings.Join(perfdata, " ")
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check health of things (monitoring plugin)",
+	Long: `Evaluate all enabled things against their last seen interval and optional
+per class value limits. Result is printed as one line with perfdata and exit
+code is set according to monitoring plugin conventions:
+0 - OK, 1 - WARNING, 2 - CRITICAL, 3 - UNKNOWN`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if config_check_warning <= 0 || config_check_critical < config_check_warning {
+			fmt.Fprintf(cmd.OutOrStdout(), "PIOT UNKNOWN - invalid multipliers: warning=%v, critical=%v\n", config_check_warning, config_check_critical)
+			os.Exit(CHECK_UNKNOWN)
+		}
+
+		state, summary := runCheck(cmd.Context(), newApiClient())
+
+		fmt.Fprintf(cmd.OutOrStdout(), "PIOT %s - %s\n", checkStateNames[state], summary)
+		os.Exit(state)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().BoolVar(&config_all, "all", false, "check things across all orgs")
+	checkCmd.Flags().StringVarP(&config_names, "names", "n", "", "limit check to particular thing names (comma separated list)")
+	checkCmd.Flags().StringVar(&config_check_type, "type", "", "limit check to things of given type (device, sensor, switch)")
+	checkCmd.Flags().Float64VarP(&config_check_warning, "warning", "w", 1, "warning if thing is not seen for longer than last seen interval multiplied by this value")
+	checkCmd.Flags().Float64VarP(&config_check_critical, "critical", "c", 2, "critical if thing is not seen for longer than last seen interval multiplied by this value")
+	checkCmd.Flags().StringVar(&config_check_ignore, "ignore", "", "comma separated list of name or alias patterns to be ignored (e.g. B3006-*)")
+	checkCmd.Flags().StringArrayVar(&config_check_limits, "limit", nil, "value limits for sensor class in form class=min:max (e.g. temperature=-30:60), could be repeated")
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"piot-cli/api"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckThingLastSeen(t *testing.T) {
+
+	config_check_warning = 1
+	config_check_critical = 2
+
+	now := int32(time.Now().Unix())
+
+	cases := []struct {
+		name    string
+		thing   api.Thing
+		state   int
+		problem string
+	}{
+		{"fresh", api.Thing{LastSeen: now - 10, LastSeenInterval: 60}, CHECK_OK, ""},
+		{"warning", api.Thing{LastSeen: now - 90, LastSeenInterval: 60}, CHECK_WARNING, "not seen for "},
+		{"critical", api.Thing{LastSeen: now - 150, LastSeenInterval: 60}, CHECK_CRITICAL, "not seen for "},
+		{"never seen", api.Thing{LastSeen: 0, LastSeenInterval: 60}, CHECK_CRITICAL, "never seen"},
+		{"not monitored", api.Thing{LastSeen: 0, LastSeenInterval: 0}, CHECK_OK, ""},
+	}
+
+	for _, c := range cases {
+		state, problem := checkThing(&c.thing, nil)
+		// age depends on time of check, only start of problem is compared
+		if state != c.state || !strings.HasPrefix(problem, c.problem) || (c.problem == "" && problem != "") {
+			t.Errorf("%s: expected (%d, %q), got (%d, %q)", c.name, c.state, c.problem, state, problem)
+		}
+	}
+}
+
+func TestCheckThingValueLimits(t *testing.T) {
+
+	limits, err := parseValueLimits([]string{"temperature=-30:60", "humidity=:90"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		sensor api.SensorData
+		state  int
+	}{
+		{api.SensorData{Class: "temperature", Value: "21.5"}, CHECK_OK},
+		{api.SensorData{Class: "temperature", Value: "-31"}, CHECK_CRITICAL},
+		{api.SensorData{Class: "humidity", Value: "95"}, CHECK_CRITICAL},
+		{api.SensorData{Class: "humidity", Value: "n/a"}, CHECK_CRITICAL},
+		{api.SensorData{Class: "pressure", Value: "2000"}, CHECK_OK},
+	}
+
+	for _, c := range cases {
+		thing := api.Thing{Sensor: c.sensor}
+		if state, problem := checkThing(&thing, limits); state != c.state {
+			t.Errorf("%s=%s: expected state %d, got %d (%s)", c.sensor.Class, c.sensor.Value, c.state, state, problem)
+		}
+	}
+}
+
+func TestPerfdataLabel(t *testing.T) {
+
+	cases := map[string]string{
+		"B3007_age":        "'B3007_age'",
+		"Bob's sensor_age": "'Bob''s sensor_age'",
+		"''":               "''''''",
+	}
+
+	for label, expected := range cases {
+		if result := perfdataLabel(label); result != expected {
+			t.Errorf("%q: expected %q, got %q", label, expected, result)
+		}
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		setup   func()
+		state   int
+		summary string
+	}{
+		{
+			name:    "active org",
+			state:   CHECK_CRITICAL,
+			summary: "2 things checked, 0 warning, 1 critical: B3007-Temp never seen | things=2;;;0; warning=0;;;0; critical=1;;;0;",
+		},
+		{
+			name:    "all orgs",
+			setup:   func() { config_all = true },
+			state:   CHECK_CRITICAL,
+			summary: "3 things checked, 0 warning, 2 critical: B3007-Temp never seen, C1-Temp value -3 out of range | things=3;;;0; warning=0;;;0; critical=2;;;0;",
+		},
+		{
+			name:    "ignored",
+			setup:   func() { config_check_ignore = "Kitchen" },
+			state:   CHECK_OK,
+			summary: "1 things checked, 0 warning, 0 critical | things=1;;;0; warning=0;;;0; critical=0;;;0;",
+		},
+		{
+			name:    "invalid limit",
+			setup:   func() { config_check_limits = []string{"temperature"} },
+			state:   CHECK_UNKNOWN,
+			summary: "Invalid",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server, client := newTestServer(t)
+			seedThings(server)
+
+			// disabled humidity sensor is not checked
+			things := server.Things()
+			for i := range things {
+				if things[i].Name == "B3007-Temp" {
+					interval := int32(60)
+					_, err := client.UpdateThingContext(context.Background(), things[i].Id, &api.ThingAttributes{LastSeenInterval: &interval})
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			config_check_limits = []string{"temperature=-2:30"}
+			if c.setup != nil {
+				c.setup()
+			}
+
+			state, summary := runCheck(context.Background(), client)
+			if state != c.state || !strings.HasPrefix(summary, c.summary) {
+				t.Errorf("expected %d %q, got %d %q", c.state, c.summary, state, summary)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package cmd
 
 import (
 	"fmt"
-	"reflect"
+	"sort"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -23,32 +23,36 @@ func contains(s []string, str string) bool {
 	return false
 }
 
+// printSettings prints nested settings (e.g. contexts), values of secret
+// keys are masked
+func printSettings(settings map[string]interface{}, indent string) {
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		val := settings[key]
+		if contains(blackList, key) {
+			val = "*****"
+		}
+		if m, ok := val.(map[string]interface{}); ok {
+			fmt.Printf("%s%s:\n", indent, key)
+			printSettings(m, indent+"  ")
+		} else {
+			fmt.Printf("%s%s: %v\n", indent, key, val)
+		}
+	}
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage configuration",
 	Long:  ``,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		settings := viper.AllSettings()
-
-		for key, val := range settings {
-			if contains(blackList, key) {
-				val = "*****"
-			}
-			v := reflect.ValueOf(val)
-			if v.Kind() == reflect.Map {
-				fmt.Printf("%s:\n", key)
-				if m, ok := val.(map[string]interface{}); ok {
-					for key2, val2 := range m {
-						if contains(blackList, key2) {
-							val2 = "*****"
-						}
-						fmt.Printf("  %s: %v\n", key2, val2)
-					}
-				}
-			} else {
-				fmt.Printf("%s: %v\n", key, val)
-			}
-		}
+		printSettings(viper.AllSettings(), "")
 		return nil
 	},
 }
@@ -0,0 +1,581 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CONFIG_KEY_CONTEXTS        = "contexts"
+	CONFIG_KEY_CURRENT_CONTEXT = "current-context"
+)
+
+// configuration keys which could be set per context, mapped to names of
+// global flags which take precedence over context values
+var contextKeys = map[string]string{
+	"piot.url":          "piot-url",
+	"piot.user":         "piot-user",
+	"piot.password":     "piot-password",
+	"influxdb.url":      "influxdb-url",
+	"influxdb.user":     "influxdb-user",
+	"influxdb.password": "influxdb-password",
+	"org":               "org",
+
+	// connection settings are usually specific for site
+	"http.timeout":             "timeout",
+	"http.connect_timeout":     "connect-timeout",
+	"http.retries":             "retries",
+	"http.proxy":               "proxy",
+	"tls.ca_file":              "ca-file",
+	"tls.cert_file":            "cert-file",
+	"tls.key_file":             "key-file",
+	"tls.insecure_skip_verify": "insecure-skip-verify",
+}
+
+var (
+	config_context     string
+	config_default_org string
+)
+
+// getContextNames returns sorted names of contexts defined in config file
+func getContextNames() []string {
+	var names []string
+	for name := range viper.GetStringMap(CONFIG_KEY_CONTEXTS) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// contextName normalizes name of context, viper keys are case insensitive
+func contextName(name string) string {
+	return strings.ToLower(name)
+}
+
+// getActiveContext returns name of context selected by --context flag
+// or current-context key of config file
+func getActiveContext() string {
+	if config_context != "" {
+		return contextName(config_context)
+	}
+	return contextName(viper.GetString(CONFIG_KEY_CURRENT_CONTEXT))
+}
+
+// isKeyOverridden returns true if configuration key is set by command line flag
+// or environment variable, such values take precedence over context values
+func isKeyOverridden(key string) bool {
+
+	if flag, ok := contextKeys[key]; ok {
+		if f := rootCmd.PersistentFlags().Lookup(flag); f != nil && f.Changed {
+			return true
+		}
+	}
+
+	env := "PIOT_" + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+	_, ok := os.LookupEnv(env)
+
+	return ok
+}
+
+// resolveContext applies values of active context to global configuration.
+// Context replaces all context keys, keys which are not set by context get
+// default values of their flags, so top level values (e.g. password for other
+// server) are never mixed with values of context.
+func resolveContext() error {
+
+	name := getActiveContext()
+	if name == "" {
+		return nil
+	}
+
+	// lookup by map to avoid interpretation of dots in context name
+	if _, ok := viper.GetStringMap(CONFIG_KEY_CONTEXTS)[name]; !ok {
+		return fmt.Errorf("Context '%s' does not exist", name)
+	}
+
+	ctx := viper.Sub(CONFIG_KEY_CONTEXTS + "." + name)
+	if ctx == nil {
+		return fmt.Errorf("Context '%s' is empty", name)
+	}
+
+	log.Infof("Using context: '%s'", name)
+
+	for key, flag := range contextKeys {
+		if isKeyOverridden(key) {
+			continue
+		}
+		if ctx.IsSet(key) {
+			viper.Set(key, ctx.GetString(key))
+		} else {
+			viper.Set(key, rootCmd.PersistentFlags().Lookup(flag).DefValue)
+		}
+	}
+
+	return nil
+}
+
+// configFile provides access to raw content of yaml config file, viper is not
+// used for writing since it would store also values of flags and env variables.
+// File is edited as tree of yaml nodes, so comments and order of keys are kept.
+type configFile struct {
+	path string
+	doc  *yaml.Node
+}
+
+func loadConfigFile() (*configFile, error) {
+
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".piot.yaml")
+	}
+
+	return readConfigFile(path)
+}
+
+// readConfigFile reads config file from path, missing file is handled as
+// empty one
+func readConfigFile(path string) (*configFile, error) {
+
+	result := &configFile{path: path, doc: &yaml.Node{Kind: yaml.DocumentNode}}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(content) > 0 {
+		err = yaml.Unmarshal(content, result.doc)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse config file '%s': %v", path, err)
+		}
+	}
+
+	// file without any content or with comments only
+	if result.doc.Kind != yaml.DocumentNode {
+		result.doc = &yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if len(result.doc.Content) == 0 {
+		result.doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+	if result.doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("Cannot parse config file '%s': top level value is not a map", path)
+	}
+
+	return result, nil
+}
+
+func (f *configFile) save() error {
+
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	err := encoder.Encode(f.doc)
+	if err != nil {
+		return err
+	}
+	encoder.Close()
+
+	content := buf.Bytes()
+	if !bytes.HasPrefix(content, []byte("---")) {
+		content = append([]byte("---\n"), content...)
+	}
+
+	// config file contains credentials
+	return ioutil.WriteFile(f.path, content, 0600)
+}
+
+// root returns top level map of config file
+func (f *configFile) root() *yaml.Node {
+	return f.doc.Content[0]
+}
+
+// helpers for yaml map nodes, keys are compared case insensitive as in viper
+
+func nodeGet(m *yaml.Node, key string) (*yaml.Node, bool) {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil, false
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if strings.EqualFold(m.Content[i].Value, key) {
+			return m.Content[i+1], true
+		}
+	}
+	return nil, false
+}
+
+// nodeSetString sets string value of key, existing value node is reused to
+// keep its comments
+func nodeSetString(m *yaml.Node, key string, value string) {
+	if existing, ok := nodeGet(m, key); ok && existing.Kind == yaml.ScalarNode {
+		existing.Tag = "!!str"
+		existing.Value = value
+		return
+	}
+	nodeSet(m, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
+
+func nodeSet(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if strings.EqualFold(m.Content[i].Value, key) {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// nodeMap returns map stored under key, map is created if it doesn't exist
+func nodeMap(m *yaml.Node, key string) *yaml.Node {
+	if existing, ok := nodeGet(m, key); ok && existing.Kind == yaml.MappingNode {
+		return existing
+	}
+	result := &yaml.Node{Kind: yaml.MappingNode}
+	nodeSet(m, key, result)
+	return result
+}
+
+func nodeDelete(m *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if strings.EqualFold(m.Content[i].Value, key) {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// nodeUpdatePath updates value of existing key given by path, key could
+// be stored as nested maps (piot: {password: x}) or as one dotted key
+// (piot.password: x). False is returned if key does not exist.
+func nodeUpdatePath(m *yaml.Node, path []string, value string) bool {
+
+	if _, ok := nodeGet(m, strings.Join(path, ".")); ok {
+		nodeSetString(m, strings.Join(path, "."), value)
+		return true
+	}
+
+	if len(path) < 2 {
+		return false
+	}
+
+	if nested, ok := nodeGet(m, path[0]); ok {
+		return nodeUpdatePath(nested, path[1:], value)
+	}
+
+	return false
+}
+
+// updateStoredValue updates configuration key stored in active context or at
+// top level of config file, false is returned if key is not stored in file
+func (f *configFile) updateStoredValue(key string, value string) bool {
+
+	path := strings.Split(key, ".")
+
+	if name := getActiveContext(); name != "" {
+		if ctx, ok := nodeGet(f.contexts(), name); ok && nodeUpdatePath(ctx, path, value) {
+			return true
+		}
+	}
+
+	return nodeUpdatePath(f.root(), path, value)
+}
+
+// contexts returns map of contexts or nil if there are no contexts
+func (f *configFile) contexts() *yaml.Node {
+	if value, ok := nodeGet(f.root(), CONFIG_KEY_CONTEXTS); ok && value.Kind == yaml.MappingNode {
+		return value
+	}
+	return nil
+}
+
+// useContext sets current context, context must exist
+func (f *configFile) useContext(name string) error {
+
+	if _, ok := nodeGet(f.contexts(), name); !ok {
+		return fmt.Errorf("Context '%s' does not exist", name)
+	}
+
+	nodeSetString(f.root(), CONFIG_KEY_CURRENT_CONTEXT, name)
+
+	return nil
+}
+
+// configValue is value of configuration key (e.g. piot.url)
+type configValue struct {
+	key   string
+	value string
+}
+
+// setContextValues creates context or updates its values, empty values are
+// not stored
+func (f *configFile) setContextValues(name string, values []configValue) {
+
+	ctx := nodeMap(nodeMap(f.root(), CONFIG_KEY_CONTEXTS), name)
+
+	for _, v := range values {
+		if v.value == "" {
+			continue
+		}
+
+		// keys are stored as nested maps (piot: {url: x})
+		m := ctx
+		path := strings.Split(v.key, ".")
+		for _, section := range path[:len(path)-1] {
+			m = nodeMap(m, section)
+		}
+		nodeSetString(m, path[len(path)-1], v.value)
+	}
+}
+
+// removeContext removes context, current-context is removed too if it points
+// to removed context
+func (f *configFile) removeContext(name string) error {
+
+	if f.contexts() == nil || !nodeDelete(f.contexts(), name) {
+		return fmt.Errorf("Context '%s' does not exist", name)
+	}
+
+	if current, ok := nodeGet(f.root(), CONFIG_KEY_CURRENT_CONTEXT); ok && current.Value == name {
+		nodeDelete(f.root(), CONFIG_KEY_CURRENT_CONTEXT)
+	}
+
+	return nil
+}
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage named contexts (PIOT servers and accounts)",
+	Long:  ``,
+}
+
+// runContextList prints table of contexts defined in config file
+func runContextList(out io.Writer) {
+
+	current := getActiveContext()
+
+	w := tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	fmt.Fprintf(w, "NAME\tCURRENT\tPIOT URL\tUSER\tINFLUXDB URL\tORG\t\n")
+	for _, name := range getContextNames() {
+		ctx := viper.Sub(CONFIG_KEY_CONTEXTS + "." + name)
+		if ctx == nil {
+			continue
+		}
+
+		isCurrent := ""
+		if name == current {
+			isCurrent = "X"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			name,
+			isCurrent,
+			ctx.GetString("piot.url"),
+			ctx.GetString("piot.user"),
+			ctx.GetString("influxdb.url"),
+			ctx.GetString("org"),
+		)
+	}
+	w.Flush()
+}
+
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List contexts",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		runContextList(cmd.OutOrStdout())
+	},
+}
+
+func runContextCurrent(out io.Writer) error {
+
+	current := getActiveContext()
+	if current == "" {
+		return fmt.Errorf("No context is active")
+	}
+
+	fmt.Fprintln(out, current)
+
+	return nil
+}
+
+var contextCurrentCmd = &cobra.Command{
+	Use:   "current",
+	Short: "Print name of active context",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runContextCurrent(cmd.OutOrStdout())
+		handleError(err)
+	},
+}
+
+func runContextUse(out io.Writer, name string) error {
+
+	name = contextName(name)
+
+	f, err := loadConfigFile()
+	if err != nil {
+		return err
+	}
+
+	err = f.useContext(name)
+	if err != nil {
+		return err
+	}
+
+	err = f.save()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Switched to context '%s'\n", name)
+
+	return nil
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use NAME",
+	Short: "Set current context",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runContextUse(cmd.OutOrStdout(), args[0])
+		handleError(err)
+	},
+}
+
+// connection settings stored by context add if their flags are set, flags
+// with non empty defaults (e.g. --timeout) would be stored always otherwise
+var contextConnectionKeys = []string{
+	"http.timeout",
+	"http.connect_timeout",
+	"http.retries",
+	"http.proxy",
+	"tls.ca_file",
+	"tls.cert_file",
+	"tls.key_file",
+	"tls.insecure_skip_verify",
+}
+
+// getContextValues returns values of context taken from global flags and
+// --default-org flag
+func getContextValues() []configValue {
+
+	values := []configValue{
+		{"piot.url", config_piot_url},
+		{"piot.user", config_piot_user},
+		{"piot.password", config_piot_password},
+		{"influxdb.url", config_influxdb_url},
+		{"influxdb.user", config_influxdb_user},
+		{"influxdb.password", config_influxdb_password},
+		{"org", config_default_org},
+	}
+
+	for _, key := range contextConnectionKeys {
+		if f := rootCmd.PersistentFlags().Lookup(contextKeys[key]); f.Changed {
+			values = append(values, configValue{key, f.Value.String()})
+		}
+	}
+
+	return values
+}
+
+func runContextAdd(out io.Writer, name string) error {
+
+	name = contextName(name)
+
+	f, err := loadConfigFile()
+	if err != nil {
+		return err
+	}
+
+	f.setContextValues(name, getContextValues())
+
+	err = f.save()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Context '%s' saved to '%s'\n", name, f.path)
+
+	return nil
+}
+
+var contextAddCmd = &cobra.Command{
+	Use:   "add NAME",
+	Short: "Add new context or update existing one",
+	Long: `Add new context or update existing one. Context values are taken from global
+flags (--piot-url, --piot-user, --piot-password, --influxdb-url,
+--influxdb-user, --influxdb-password, --timeout, --connect-timeout, --retries,
+--proxy, --ca-file, --cert-file, --key-file, --insecure-skip-verify) and
+--default-org flag.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runContextAdd(cmd.OutOrStdout(), args[0])
+		handleError(err)
+	},
+}
+
+func runContextRemove(out io.Writer, name string) error {
+
+	name = contextName(name)
+
+	f, err := loadConfigFile()
+	if err != nil {
+		return err
+	}
+
+	err = f.removeContext(name)
+	if err != nil {
+		return err
+	}
+
+	err = f.save()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Context '%s' removed\n", name)
+
+	return nil
+}
+
+var contextRemoveCmd = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "Remove context",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runContextRemove(cmd.OutOrStdout(), args[0])
+		handleError(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(contextCmd)
+
+	contextCmd.AddCommand(contextListCmd)
+	contextCmd.AddCommand(contextCurrentCmd)
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextAddCmd)
+	contextAddCmd.Flags().StringVar(&config_default_org, "default-org", "", "default organization of the context")
+	contextCmd.AddCommand(contextRemoveCmd)
+}
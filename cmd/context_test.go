@@ -0,0 +1,411 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"piot-cli/api"
+
+	"github.com/spf13/viper"
+)
+
+const testConfig = `---
+# my comment
+log:
+  level: INFO # inline comment
+
+piot:
+  url: https://piot.example.com
+  user: admin@example.com
+  password: secret # keep me private
+
+# contexts of other sites
+contexts:
+  home:
+    piot:
+      url: https://home.example.com
+    org: HOME
+current-context: home
+`
+
+// writeTestConfig writes config file into temporary directory and returns its
+// path
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "piot-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, ".piot.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// saveAndRead saves config file and returns its content
+func saveAndRead(t *testing.T, f *configFile) string {
+	t.Helper()
+
+	if err := f.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func assertContains(t *testing.T, content string, expected ...string) {
+	t.Helper()
+	for _, e := range expected {
+		if !strings.Contains(content, e) {
+			t.Errorf("expected %q in:\n%s", e, content)
+		}
+	}
+}
+
+func assertNotContains(t *testing.T, content string, unexpected ...string) {
+	t.Helper()
+	for _, u := range unexpected {
+		if strings.Contains(content, u) {
+			t.Errorf("unexpected %q in:\n%s", u, content)
+		}
+	}
+}
+
+var testConfigComments = []string{
+	"# my comment",
+	"# inline comment",
+	"# keep me private",
+	"# contexts of other sites",
+}
+
+func TestConfigFileKeepsComments(t *testing.T) {
+
+	f, err := readConfigFile(writeTestConfig(t, testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.setContextValues("work", []configValue{
+		{"piot.url", "https://work.example.com"},
+		{"piot.user", "me@work.example.com"},
+		{"piot.password", ""},
+		{"org", "WORK"},
+	})
+
+	if err := f.useContext("work"); err != nil {
+		t.Fatal(err)
+	}
+
+	content := saveAndRead(t, f)
+
+	assertContains(t, content, testConfigComments...)
+	assertContains(t, content,
+		"current-context: work",
+		"url: https://work.example.com",
+		"org: WORK",
+	)
+
+	f, err = readConfigFile(f.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.removeContext("work"); err != nil {
+		t.Fatal(err)
+	}
+
+	content = saveAndRead(t, f)
+
+	assertContains(t, content, testConfigComments...)
+	assertNotContains(t, content, "work", "current-context")
+}
+
+func TestConfigFileUpdateStoredValue(t *testing.T) {
+
+	f, err := readConfigFile(writeTestConfig(t, testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config_context = ""
+	defer func() { config_context = "" }()
+
+	if !f.updateStoredValue("piot.password", "new-secret") {
+		t.Fatal("password stored at top level not updated")
+	}
+
+	// value of active context is updated, top level value is kept
+	config_context = "home"
+	if !f.updateStoredValue("piot.url", "https://new.example.com") {
+		t.Fatal("url stored in context not updated")
+	}
+
+	if f.updateStoredValue("influxdb.url", "http://localhost:8086") {
+		t.Error("key which is not stored in file must not be added")
+	}
+
+	content := saveAndRead(t, f)
+
+	assertContains(t, content, testConfigComments...)
+	assertContains(t, content,
+		"password: new-secret # keep me private",
+		"url: https://piot.example.com",
+		"url: https://new.example.com",
+	)
+	assertNotContains(t, content, "influxdb", "home.example.com")
+}
+
+func TestConfigFileDottedKeys(t *testing.T) {
+
+	f, err := readConfigFile(writeTestConfig(t, "piot.user: a@example.com # flat\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config_context = ""
+
+	if !f.updateStoredValue("piot.user", "b@example.com") {
+		t.Fatal("dotted key not updated")
+	}
+
+	assertContains(t, saveAndRead(t, f), "piot.user: b@example.com # flat")
+}
+
+func TestConfigFileMissing(t *testing.T) {
+
+	path := filepath.Join(filepath.Dir(writeTestConfig(t, "")), "missing.yaml")
+
+	f, err := readConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.useContext("home"); err == nil {
+		t.Error("expected error for missing context")
+	}
+
+	f.setContextValues("home", []configValue{{"piot.url", "https://home.example.com"}})
+
+	content := saveAndRead(t, f)
+	assertContains(t, content, "contexts:", "home:", "url: https://home.example.com")
+}
+
+// useTestConfig makes viper read config file with given content, values of
+// context keys and config are reset at the end of test
+func useTestConfig(t *testing.T, content string) {
+	t.Helper()
+
+	viper.SetConfigFile(writeTestConfig(t, content))
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		config_context = ""
+		viper.SetConfigFile(writeTestConfig(t, ""))
+		viper.ReadInConfig()
+		viper.SetConfigFile("")
+		for key, flag := range contextKeys {
+			viper.Set(key, rootCmd.PersistentFlags().Lookup(flag).DefValue)
+		}
+	})
+}
+
+func TestResolveContextIgnoresTopLevelValues(t *testing.T) {
+
+	useTestConfig(t, `---
+piot:
+  url: https://piot.example.com
+  user: admin@example.com
+  password: production-secret
+influxdb:
+  url: https://influxdb.example.com
+  user: influx
+  password: influx-secret
+tls:
+  cert_file: /certs/production.pem
+http:
+  retries: 7
+org: JASO
+
+contexts:
+  staging:
+    piot:
+      url: https://staging.example.com
+      user: tester@example.com
+    http:
+      timeout: 5s
+`)
+
+	config_context = "staging"
+	if err := resolveContext(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"piot.url":             "https://staging.example.com",
+		"piot.user":            "tester@example.com",
+		"piot.password":        "",
+		"influxdb.url":         "",
+		"influxdb.user":        "",
+		"influxdb.password":    "",
+		"tls.cert_file":        "",
+		"org":                  "",
+		"http.timeout":         "5s",
+		"http.retries":         rootCmd.PersistentFlags().Lookup("retries").DefValue,
+		"http.connect_timeout": rootCmd.PersistentFlags().Lookup("connect-timeout").DefValue,
+	}
+
+	for key, value := range expected {
+		if result := viper.GetString(key); result != value {
+			t.Errorf("%s: expected %q, got %q", key, value, result)
+		}
+	}
+
+	if viper.GetDuration("http.timeout") != 5*time.Second || viper.GetInt("http.retries") != api.DEFAULT_RETRIES {
+		t.Errorf("unexpected http settings: timeout %v, retries %d", viper.GetDuration("http.timeout"), viper.GetInt("http.retries"))
+	}
+}
+
+func TestResolveContextKeepsOverriddenValues(t *testing.T) {
+
+	useTestConfig(t, `---
+contexts:
+  staging:
+    piot:
+      url: https://staging.example.com
+`)
+
+	// value provided by environment variable is kept as it is
+	os.Setenv("PIOT_PIOT_PASSWORD", "from-env")
+	defer os.Unsetenv("PIOT_PIOT_PASSWORD")
+	viper.Set("piot.password", "from-env")
+
+	config_context = "staging"
+	if err := resolveContext(); err != nil {
+		t.Fatal(err)
+	}
+
+	if password := viper.GetString("piot.password"); password != "from-env" {
+		t.Errorf("expected password from environment, got %q", password)
+	}
+}
+
+func TestContextAddStoresConnectionSettings(t *testing.T) {
+
+	flags := map[string]string{
+		"piot-url":             "https://customer.example.com",
+		"proxy":                "http://proxy.example.com:3128",
+		"ca-file":              "/certs/ca.pem",
+		"cert-file":            "/certs/me.pem",
+		"key-file":             "/certs/me-key.pem",
+		"insecure-skip-verify": "true",
+		"timeout":              "2m0s",
+	}
+
+	for name, value := range flags {
+		f := rootCmd.PersistentFlags().Lookup(name)
+		defaultValue := f.DefValue
+		if err := f.Value.Set(value); err != nil {
+			t.Fatal(err)
+		}
+		f.Changed = true
+		defer func() {
+			f.Value.Set(defaultValue)
+			f.Changed = false
+		}()
+	}
+
+	f, err := readConfigFile(writeTestConfig(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.setContextValues("customer", getContextValues())
+
+	content := saveAndRead(t, f)
+
+	assertContains(t, content,
+		"url: https://customer.example.com",
+		"proxy: http://proxy.example.com:3128",
+		"timeout: 2m0s",
+		"ca_file: /certs/ca.pem",
+		"cert_file: /certs/me.pem",
+		"key_file: /certs/me-key.pem",
+		`insecure_skip_verify: "true"`,
+	)
+
+	// flags which are not set are not stored, even if they have default
+	assertNotContains(t, content, "connect_timeout", "retries", "influxdb")
+}
+
+func TestContextCommandsWriteToOutput(t *testing.T) {
+
+	useTestConfig(t, `---
+contexts:
+  home:
+    piot:
+      url: https://home.example.com
+      user: me@example.com
+`)
+
+	var out bytes.Buffer
+
+	config_piot_url = "https://customer.example.com"
+	defer func() { config_piot_url = "" }()
+
+	if err := runContextAdd(&out, "Customer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := runContextUse(&out, "customer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := runContextCurrent(&out); err != nil {
+		t.Fatal(err)
+	}
+	runContextList(&out)
+	if err := runContextRemove(&out, "customer"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContains(t, out.String(),
+		"Context 'customer' saved to '"+viper.ConfigFileUsed()+"'\n",
+		"Switched to context 'customer'\n",
+		"customer\nNAME",
+		"home                 https://home.example.com       me@example.com",
+		"customer   X         https://customer.example.com",
+		"Context 'customer' removed\n",
+	)
+
+	if err := runContextRemove(&out, "customer"); err == nil {
+		t.Errorf("expected error when removing missing context")
+	}
+}
+
+func TestContextCurrentWithoutActiveContext(t *testing.T) {
+
+	useTestConfig(t, "---\n")
+
+	var out bytes.Buffer
+	if err := runContextCurrent(&out); err == nil {
+		t.Errorf("expected error, got output %q", out.String())
+	}
+}
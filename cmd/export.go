@@ -2,26 +2,28 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"piot-cli/api"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/360EntSecGroup-Skylar/excelize/v2"
-	influx "github.com/influxdata/influxdb1-client/v2"
 	"github.com/jszwec/csvutil"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var (
-	config_from   string
-	config_to     string
-	config_names  string
-	config_output string
+	config_from     string
+	config_to       string
+	config_names    string
+	config_output   string
+	config_orgs     string
+	config_all_orgs bool
 )
 
 const TIME_LAYOUT string = "2006-01-02"
@@ -80,7 +82,15 @@ func PrepareTabularData(sensor_data map[string][]SensorValue) ([]string, []time.
 
 	header := []string{"date"}
 
-	for sensor_name, sensor_values := range sensor_data {
+	// columns are sorted by sensor name, order of map keys is random
+	sensor_names := make([]string, 0, len(sensor_data))
+	for sensor_name := range sensor_data {
+		sensor_names = append(sensor_names, sensor_name)
+	}
+	sort.Strings(sensor_names)
+
+	for _, sensor_name := range sensor_names {
+		sensor_values := sensor_data[sensor_name]
 		header = append(header, sensor_name)
 
 		// go through global table rows
@@ -115,7 +125,9 @@ func PrepareTabularData(sensor_data map[string][]SensorValue) ([]string, []time.
 func SensorData2CsvRows(sensor_data map[string][]SensorValue) (string, error) {
 
 	header, time_stamps_sorted, rows, err := PrepareTabularData(sensor_data)
-	handleError(err)
+	if err != nil {
+		return "", err
+	}
 
 	// build csv
 	var records [][]string
@@ -150,26 +162,102 @@ func SensorData2CsvRows(sensor_data map[string][]SensorValue) (string, error) {
 }
 
 func SensorData2Excel(sensor_data map[string][]SensorValue, output_file_path string) error {
+	return SensorSheets2Excel([]string{"sensors"}, map[string]map[string][]SensorValue{"sensors": sensor_data}, output_file_path)
+}
 
-	header, time_stamps_sorted, rows, err := PrepareTabularData(sensor_data)
-	handleError(err)
+// maximal length of sheet name in xlsx file
+const EXCEL_SHEET_NAME_MAX_LENGTH = 31
+
+// excelSheetNames returns valid and unique names of sheets. Characters not
+// allowed by Excel are replaced, names are shortened to maximal length and
+// duplicates (case insensitive) get suffix (e.g. ~2).
+func excelSheetNames(names []string) []string {
+
+	replacer := strings.NewReplacer(":", "_", `\`, "_", "/", "_", "?", "_", "*", "_", "[", "_", "]", "_")
+
+	truncate := func(name string, length int) string {
+		if runes := []rune(name); len(runes) > length {
+			return string(runes[:length])
+		}
+		return name
+	}
+
+	var result []string
+	used := map[string]bool{}
+
+	for _, name := range names {
+		name = strings.Trim(replacer.Replace(name), "'")
+		if name == "" {
+			name = "sheet"
+		}
+
+		unique := truncate(name, EXCEL_SHEET_NAME_MAX_LENGTH)
+		for i := 2; used[strings.ToLower(unique)]; i++ {
+			suffix := fmt.Sprintf("~%d", i)
+			unique = truncate(name, EXCEL_SHEET_NAME_MAX_LENGTH-len(suffix)) + suffix
+		}
+
+		used[strings.ToLower(unique)] = true
+		result = append(result, unique)
+	}
+
+	return result
+}
+
+// SensorSheets2Excel writes sensor data to xlsx file, one sheet is created for
+// each name from sheet_names (in given order)
+func SensorSheets2Excel(sheet_names []string, sheets map[string]map[string][]SensorValue, output_file_path string) error {
 
 	// build xlsx
 	f := excelize.NewFile()
 
-	// Get first sheet and rename it to sensors
-	sheet_name := f.GetSheetList()[0]
-	sheet_ix := f.GetSheetIndex(sheet_name)
-	f.SetSheetName(sheet_name, "sensors")
-	sheet_name = "sensors"
+	// Get first sheet and rename it
+	first_sheet_name := f.GetSheetList()[0]
+	sheet_ix := f.GetSheetIndex(first_sheet_name)
+
+	// names could be too long or contain invalid characters (e.g. org names)
+	titles := excelSheetNames(sheet_names)
+
+	for i, sheet_name := range sheet_names {
+		if i == 0 {
+			f.SetSheetName(first_sheet_name, titles[i])
+		} else {
+			f.NewSheet(titles[i])
+		}
+
+		err := writeSensorSheet(f, titles[i], sheets[sheet_name])
+		if err != nil {
+			return err
+		}
+	}
 
 	// Set active sheet of the workbook.
 	f.SetActiveSheet(sheet_ix)
 
+	/*
+		this is how to write xlsx stream to stdout
+		buf := bytes.NewBufferString("")
+		_, err = f.WriteTo(buf)
+		handleError(err)
+	*/
+
+	// Save spreadsheet by the given path.
+	return f.SaveAs(output_file_path)
+}
+
+func writeSensorSheet(f *excelize.File, sheet_name string, sensor_data map[string][]SensorValue) error {
+
+	header, time_stamps_sorted, rows, err := PrepareTabularData(sensor_data)
+	if err != nil {
+		return err
+	}
+
 	// header
 	for i, sensor_name := range header {
 		cell_name, err := excelize.CoordinatesToCellName(i+1, 1, false)
-		handleError(err)
+		if err != nil {
+			return err
+		}
 		f.SetCellValue(sheet_name, cell_name, sensor_name)
 	}
 
@@ -180,14 +268,18 @@ func SensorData2Excel(sensor_data map[string][]SensorValue, output_file_path str
 
 		// write timestamp
 		cell_name, err := excelize.CoordinatesToCellName(excel_col_ix, excel_row_ix, false)
-		handleError(err)
+		if err != nil {
+			return err
+		}
 		f.SetCellValue(sheet_name, cell_name, time_stamp.String())
 
 		// write values
 		excel_col_ix++
 		for _, value := range rows[time_stamp] {
 			cell_name, err := excelize.CoordinatesToCellName(excel_col_ix, excel_row_ix, false)
-			handleError(err)
+			if err != nil {
+				return err
+			}
 
 			if value == SENSOR_VALUE_EMPTY {
 				f.SetCellValue(sheet_name, cell_name, "nil")
@@ -198,195 +290,245 @@ func SensorData2Excel(sensor_data map[string][]SensorValue, output_file_path str
 		}
 		excel_row_ix++
 	}
-	/*
-		this is how to write xlsx stream to stdout
-		buf := bytes.NewBufferString("")
-		_, err = f.WriteTo(buf)
-		handleError(err)
-	*/
-
-	// Save spreadsheet by the given path.
-	err = f.SaveAs(output_file_path)
-	handleError(err)
 
 	return nil
 }
 
+// getExportOrgs returns organizations selected by --orgs or --all-orgs flags
+func getExportOrgs(ctx context.Context, client api.PiotAPI) ([]api.Org, error) {
+
+	if config_all_orgs {
+		return client.GetOrgsContext(ctx, nil)
+	}
+
+	var result []api.Org
+	for _, name := range strings.Split(config_orgs, ",") {
+		org, err := getOrgByNameOrFail(ctx, client, strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *org)
+	}
+
+	return result, nil
+}
+
 var exportCmd = &cobra.Command{
 	Use:   "export",
 	Short: "Export piot data (sensors, things, etc.)",
 	Long:  ``,
 }
 
+// runExportThings prints things of organization in json or csv format
+func runExportThings(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	things, err := getThings(ctx, client, config_all, nil)
+	if err != nil {
+		return err
+	}
+
+	if config_format == "csv" {
+		b, err := csvutil.Marshal(things)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(b))
+	} else {
+		thingsJson, err := json.MarshalIndent(things, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\n", string(thingsJson))
+	}
+
+	return nil
+}
+
 var exportThingsCmd = &cobra.Command{
 	Use:   "things",
 	Short: "Export things form current organization",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
-		client := api.NewClient(log)
-
-		err := client.Login()
+		err := runExportThings(cmd.Context(), newApiClient(), cmd.OutOrStdout())
 		handleError(err)
-
-		things, err := client.GetThings(config_all, nil)
-		handleError(err)
-
-		if config_format == "csv" {
-			b, err := csvutil.Marshal(things)
-			handleError(err)
-			fmt.Println(string(b))
-		} else {
-			thingsJson, err := json.MarshalIndent(things, "", "  ")
-			handleError(err)
-			fmt.Printf("%s\n", string(thingsJson))
-		}
 	},
 }
 
-var exportSensorsCmd = &cobra.Command{
-	Use:   "sensors",
-	Short: "Export selected sensors",
-	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+// runExportSensors exports values of sensors stored in InfluxDB databases of
+// organizations, xlsx output is written to file given by -o flag, other
+// formats to out
+func runExportSensors(ctx context.Context, client api.PiotAPI, ic *influxClient, out io.Writer) error {
 
-		var err error
+	var err error
 
-		date_to := time.Now()
-		date_from := date_to.Add((-1 * 24) * time.Hour) // last day
+	date_to := time.Now()
+	date_from := date_to.Add((-1 * 24) * time.Hour) // last day
 
-		if config_from != "" {
-			// convert from and to time.Time
-			date_from, err = time.Parse(TIME_LAYOUT, config_from)
-			handleError(err)
+	if config_from != "" {
+		// convert from and to time.Time
+		date_from, err = time.Parse(TIME_LAYOUT, config_from)
+		if err != nil {
+			return err
 		}
+	}
 
-		if config_to != "" {
-			// convert from and to time.Time
-			date_to, err = time.Parse(TIME_LAYOUT, config_to)
-			handleError(err)
+	if config_to != "" {
+		// convert from and to time.Time
+		date_to, err = time.Parse(TIME_LAYOUT, config_to)
+		if err != nil {
+			return err
 		}
+	}
 
-		if config_format != "" {
-			switch config_format {
-			case "csv", "json":
-			case "xlsx":
-				if config_output == "" {
-					handleError(fmt.Errorf("output format xlsx requires output to file (see -o flag)"))
-				}
-			default:
-				handleError(fmt.Errorf("Unkonwn output format: %s, try to run command with -h flag to see supported formats", config_format))
+	if config_format != "" {
+		switch config_format {
+		case "csv", "json":
+		case "xlsx":
+			if config_output == "" {
+				return fmt.Errorf("output format xlsx requires output to file (see -o flag)")
 			}
+		default:
+			return fmt.Errorf("Unkonwn output format: %s, try to run command with -h flag to see supported formats", config_format)
 		}
+	}
 
-		var names []string
-		if config_names != "" {
-			names = strings.Split(config_names, ",")
-		}
+	var names []string
+	if config_names != "" {
+		names = strings.Split(config_names, ",")
+	}
 
-		// TODO: check if to > from
+	// TODO: check if to > from
 
-		// get api client
-		client := api.NewClient(log)
-		err = client.Login()
-		handleError(err)
+	err = client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
 
-		// get active org from user profile
-		profile, err := client.GetUserProfile()
-		handleError(err)
-		org, err := profile.GetActiveOrg()
-		handleError(err)
+	// get selected org or active org from user profile, list of orgs is
+	// used for multi org exports
+	multi_org := config_all_orgs || config_orgs != ""
+	var orgs []api.Org
+	if multi_org {
+		orgs, err = getExportOrgs(ctx, client)
+		if err != nil {
+			return err
+		}
+	} else {
+		org, err := getOrg(ctx, client)
+		if err != nil {
+			return err
+		}
+		orgs = []api.Org{*org}
+	}
 
-		log.Infof("Export params:")
-		log.Infof("  from: %s", date_from)
-		log.Infof("  to: %s", date_to)
-		log.Infof("  names: %s", names)
+	log.Infof("Export params:")
+	log.Infof("  from: %s", date_from)
+	log.Infof("  to: %s", date_to)
+	log.Infof("  names: %s", names)
 
-		ic, err := influx.NewHTTPClient(influx.HTTPConfig{
-			Addr:     viper.GetString("influxdb.url"),
-			Username: viper.GetString("influxdb.user"),
-			Password: viper.GetString("influxdb.password"),
-		})
-		handleError(err)
-		defer ic.Close()
+	// sensor data of individual orgs
+	org_data := map[string]map[string][]SensorValue{}
+	var org_names []string
+
+	for _, org := range orgs {
+
+		if org.InfluxDb == "" {
+			log.Warningf("Skipping org '%s', no influxdb database assigned", org.Name)
+			continue
+		}
 
 		// get all org sensors
-		things, err := client.GetThings(false, func(thing *api.Thing) bool { return thing.Type == "sensor" })
-		handleError(err)
+		is_sensor := func(thing *api.Thing) bool { return thing.Type == "sensor" }
+		var things []api.Thing
+		if multi_org {
+			things, err = getOrgThings(ctx, client, &org, is_sensor)
+		} else {
+			things, err = getThings(ctx, client, false, is_sensor)
+		}
+		if err != nil {
+			return err
+		}
 
 		sensor_data := map[string][]SensorValue{}
 
 		// fetch data for sensors (one by one)
 		for _, thing := range things {
 
-			// filter things if names flag was specified
-			if len(names) > 0 {
-				skip := true
-				for _, name := range names {
-					if thing.Name == name {
-						skip = false
-						break
-					}
-				}
-				if skip {
-					log.Infof("Skipping sensor '%s'", thing.Name)
-					continue
-				}
+			// filter things if names flag was specified, names could be
+			// qualified by org name (ORG.sensor)
+			if len(names) > 0 && !contains(names, thing.Name) && !contains(names, org.Name+"."+thing.Name) {
+				log.Infof("Skipping sensor '%s.%s'", org.Name, thing.Name)
+				continue
 			}
 
 			log.Infof("Fetching data for sensor '%s.%s'", org.Name, thing.Name)
-			query := fmt.Sprintf(
-				"SELECT MEAN(\"value\") FROM \"sensor\" WHERE time >= '%s' AND time <= '%s' AND \"id\" = '%s' GROUP BY time(1h)",
-				date_from.Format(time.RFC3339),
-				date_to.Format(time.RFC3339),
-				thing.Id)
-
-			log.Debugf("query: %s", query)
 
-			q := influx.NewQuery(query, org.InfluxDb, "")
-
-			response, err := ic.Query(q)
-			handleError(err)
-
-			if response.Error() != nil {
-				handleError(response.Error())
+			sensor_data[thing.Name], err = fetchSensorValues(ctx, ic, org.InfluxDb, thing.Id, date_from, date_to)
+			if err != nil {
+				return err
 			}
 
-			log.Debugf("response: %s", response)
-
-			if len(response.Results[0].Series) == 0 {
+			if len(sensor_data[thing.Name]) == 0 {
 				log.Infof("No influxdb data for sensor  '%s.%s'", org.Name, thing.Name)
-				sensor_data[thing.Name] = []SensorValue{}
-				continue
 			}
+		}
 
-			sensor_data[thing.Name] = []SensorValue{}
-
-			// we are interested in results from first statement and first entry from series
-			for _, value := range response.Results[0].Series[0].Values {
-				//fmt.Printf("%v\n", value)
-
-				sensor_value, err := CreateFromInfluxResponse(value)
-				handleError(err)
+		org_data[org.Name] = sensor_data
+		org_names = append(org_names, org.Name)
+	}
 
-				sensor_data[thing.Name] = append(sensor_data[thing.Name], *sensor_value)
+	// columns are prefixed by org name in multi org exports
+	sensor_data := map[string][]SensorValue{}
+	for org_name, org_sensor_data := range org_data {
+		for sensor_name, values := range org_sensor_data {
+			if multi_org {
+				sensor_name = org_name + "." + sensor_name
 			}
+			sensor_data[sensor_name] = values
 		}
+	}
 
-		switch config_format {
-		case "csv":
-			result_csv, err := SensorData2CsvRows(sensor_data)
-			handleError(err)
-			fmt.Println(string(result_csv))
-		case "xlsx":
-			err := SensorData2Excel(sensor_data, config_output)
-			handleError(err)
-		case "json", "":
-			result_json, err := json.MarshalIndent(sensor_data, "", "  ")
-			handleError(err)
-			fmt.Println(string(result_json))
-		default:
-			handleError(fmt.Errorf("Unkonwn output format: %s", config_format))
+	switch config_format {
+	case "csv":
+		result_csv, err := SensorData2CsvRows(sensor_data)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(result_csv))
+	case "xlsx":
+		if multi_org {
+			return SensorSheets2Excel(org_names, org_data, config_output)
+		}
+		return SensorData2Excel(sensor_data, config_output)
+	case "json", "":
+		result_json, err := json.MarshalIndent(sensor_data, "", "  ")
+		if err != nil {
+			return err
 		}
+		fmt.Fprintln(out, string(result_json))
+	default:
+		return fmt.Errorf("Unkonwn output format: %s", config_format)
+	}
+
+	return nil
+}
+
+var exportSensorsCmd = &cobra.Command{
+	Use:   "sensors",
+	Short: "Export selected sensors",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		ic, err := newInfluxClient()
+		handleError(err)
+		defer ic.Close()
+
+		err = runExportSensors(cmd.Context(), newApiClient(), ic, cmd.OutOrStdout())
+		handleError(err)
 	},
 }
 
@@ -404,4 +546,6 @@ func init() {
 	exportSensorsCmd.Flags().StringVar(&config_to, "to", "", "end date in format "+TIME_LAYOUT)
 	exportSensorsCmd.Flags().StringVarP(&config_names, "names", "n", "", "limit export to particular sensor names (comma seperated list)")
 	exportSensorsCmd.Flags().StringVarP(&config_output, "output", "o", "", "path to file to write export output")
+	exportSensorsCmd.Flags().StringVar(&config_orgs, "orgs", "", "export sensors of several organizations (comma separated list), columns are prefixed by org name")
+	exportSensorsCmd.Flags().BoolVar(&config_all_orgs, "all-orgs", false, "export sensors of all organizations, columns are prefixed by org name")
 }
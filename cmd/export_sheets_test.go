@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+func TestExcelSheetNames(t *testing.T) {
+
+	long := "Organization With Very Long Name" // 32 characters
+
+	cases := []struct {
+		names    []string
+		expected []string
+	}{
+		{[]string{"JASO", "PIOT"}, []string{"JASO", "PIOT"}},
+		{[]string{"a:b", `c\d/e`, "f?g*h", "[i]", "'quoted'"}, []string{"a_b", "c_d_e", "f_g_h", "_i_", "quoted"}},
+		{[]string{long + " A", long + " B"}, []string{"Organization With Very Long Nam", "Organization With Very Long N~2"}},
+		{[]string{"jaso", "JASO", "Jaso"}, []string{"jaso", "JASO~2", "Jaso~3"}},
+		{[]string{"", "x~2", "x", "x"}, []string{"sheet", "x~2", "x", "x~3"}},
+	}
+
+	for _, c := range cases {
+		if result := excelSheetNames(c.names); !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%q: expected %q, got %q", c.names, c.expected, result)
+		}
+	}
+}
+
+func TestSensorSheets2ExcelLongNames(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "piot-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	first := "Organization With Very Long Name A"
+	second := "Organization With Very Long Name B"
+
+	sheets := map[string]map[string][]SensorValue{
+		first:  {"temperature": {{Date: ts, Value: 1.5}}},
+		second: {"humidity": {{Date: ts, Value: 45}}},
+	}
+
+	path := filepath.Join(dir, "sensors.xlsx")
+
+	err = SensorSheets2Excel([]string{first, second}, sheets, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Organization With Very Long Nam", "Organization With Very Long N~2"}
+	if names := f.GetSheetList(); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected sheets %q, got %q", expected, names)
+	}
+
+	for i, header := range []string{"temperature", "humidity"} {
+		value, err := f.GetCellValue(expected[i], "B1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != header {
+			t.Errorf("sheet %q: expected column %q, got %q", expected[i], header, value)
+		}
+	}
+}
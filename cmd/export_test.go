@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"piot-cli/api"
+	"piot-cli/apitest"
+
+	"github.com/spf13/viper"
+)
+
+func TestExportThings(t *testing.T) {
+
+	runCommandCases(t, seedThings, []commandCase{
+		{
+			name:  "export-things.json",
+			setup: func(*apitest.Server) { config_format = "json" },
+			run:   runExportThings,
+		},
+		{
+			name:  "export-things.csv",
+			setup: func(*apitest.Server) { config_format = "csv" },
+			run:   runExportThings,
+		},
+		{
+			name: "export-things-all.csv",
+			setup: func(*apitest.Server) {
+				config_format = "csv"
+				config_all = true
+			},
+			run: runExportThings,
+		},
+		{
+			name: "export-things-org.json",
+			setup: func(*apitest.Server) {
+				config_format = "json"
+				viper.Set("org", "COTTAGE")
+			},
+			run: runExportThings,
+		},
+	})
+}
+
+// seedSensors fills fake servers by fixture data: sensors with empty buckets,
+// sensors with values at different times, sensor without any data and
+// organizations with and without database
+func seedSensors(piot *apitest.Server, influx *apitest.InfluxServer) {
+
+	at := func(hour int) time.Time {
+		return time.Date(2021, 3, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	jaso := piot.AddOrg(api.Org{Name: "JASO", InfluxDb: "jaso"})
+	piot.AddThing(api.Thing{Name: "B3007", Type: "device", OrgId: jaso.Id})
+	temperature := piot.AddThing(api.Thing{Name: "temperature", Type: "sensor", OrgId: jaso.Id})
+	humidity := piot.AddThing(api.Thing{Name: "humidity", Type: "sensor", OrgId: jaso.Id})
+	piot.AddThing(api.Thing{Name: "empty", Type: "sensor", OrgId: jaso.Id})
+
+	influx.AddSensorValues(jaso.InfluxDb, temperature.Id,
+		apitest.InfluxPoint{Time: at(0), Value: apitest.Value(1.5)},
+		apitest.InfluxPoint{Time: at(1), Value: nil},
+		apitest.InfluxPoint{Time: at(2), Value: apitest.Value(2.25)},
+		apitest.InfluxPoint{Time: at(3), Value: apitest.Value(-0.5)},
+		// outside of exported interval
+		apitest.InfluxPoint{Time: at(48), Value: apitest.Value(100)},
+	)
+	influx.AddSensorValues(jaso.InfluxDb, humidity.Id,
+		apitest.InfluxPoint{Time: at(1), Value: apitest.Value(45)},
+		apitest.InfluxPoint{Time: at(2), Value: apitest.Value(47.125)},
+		apitest.InfluxPoint{Time: at(4), Value: apitest.Value(50)},
+	)
+
+	piotOrg := piot.AddOrg(api.Org{Name: "PIOT", InfluxDb: "piot"})
+	pressure := piot.AddThing(api.Thing{Name: "pressure", Type: "sensor", OrgId: piotOrg.Id})
+
+	influx.AddSensorValues(piotOrg.InfluxDb, pressure.Id,
+		apitest.InfluxPoint{Time: at(0), Value: apitest.Value(1013.25)},
+		apitest.InfluxPoint{Time: at(1), Value: apitest.Value(1012)},
+	)
+
+	// skipped by export
+	piot.AddOrg(api.Org{Name: "NODB"})
+}
+
+func TestExportSensors(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		format  string
+		names   string
+		allOrgs bool
+	}{
+		{name: "export-sensors.csv", format: "csv"},
+		{name: "export-sensors.json", format: "json"},
+		{name: "export-sensors.xlsx", format: "xlsx"},
+		{name: "export-sensors-empty.csv", format: "csv", names: "empty"},
+		{name: "export-sensors-empty.json", format: "json", names: "empty"},
+		{name: "export-sensors-all-orgs.csv", format: "csv", allOrgs: true},
+		{name: "export-sensors-all-orgs.xlsx", format: "xlsx", allOrgs: true},
+	}
+
+	dir, err := ioutil.TempDir("", "piot-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			piot, client := newTestServer(t)
+			influx := newTestInfluxServer(t)
+			seedSensors(piot, influx)
+
+			config_from = "2021-03-01"
+			config_to = "2021-03-02"
+			config_format = c.format
+			config_names = c.names
+			config_all_orgs = c.allOrgs
+			if c.format == "xlsx" {
+				config_output = filepath.Join(dir, c.name)
+			}
+
+			ic, err := newInfluxClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ic.Close()
+
+			var out bytes.Buffer
+			err = runExportSensors(context.Background(), client, ic, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.format != "xlsx" {
+				checkGolden(t, c.name, out.Bytes())
+				return
+			}
+
+			err = apitest.CompareExcelGolden(filepath.Join("testdata", c.name+".golden"), config_output)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestExportSensorsInvalidFormat(t *testing.T) {
+
+	_, client := newTestServer(t)
+	newTestInfluxServer(t)
+
+	ic, err := newInfluxClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ic.Close()
+
+	for format, expected := range map[string]string{
+		"xml":  "Unkonwn output format: xml",
+		"xlsx": "output format xlsx requires output to file",
+	} {
+		config_format = format
+		err := runExportSensors(context.Background(), client, ic, ioutil.Discard)
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("%s: expected error %q, got %v", format, expected, err)
+		}
+	}
+}
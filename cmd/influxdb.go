@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"piot-cli/api"
+	"strings"
+	"time"
+
+	influx "github.com/influxdata/influxdb1-client/v2"
+	"github.com/spf13/viper"
+)
+
+// influxClient runs queries using InfluxDB v1 HTTP API. Client of influxdb1
+// library is not used for transport since its queries don't accept context and
+// couldn't be cancelled (e.g. by Ctrl+C), its query and response types are
+// reused.
+type influxClient struct {
+	url        url.URL
+	user       string
+	password   string
+	httpClient *http.Client
+	retries    int
+}
+
+// isReadOnlyInfluxQuery returns true for statements which could be safely
+// retried
+func isReadOnlyInfluxQuery(command string) bool {
+	command = strings.ToUpper(strings.TrimSpace(command))
+	if strings.HasPrefix(command, "SHOW") {
+		return true
+	}
+	return strings.HasPrefix(command, "SELECT") && !strings.Contains(command, " INTO ")
+}
+
+// newInfluxClient creates InfluxDB client from influxdb.* configuration
+func newInfluxClient() (*influxClient, error) {
+
+	u, err := url.Parse(viper.GetString("influxdb.url"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("Unsupported protocol scheme of InfluxDB url: '%s'", u.Scheme)
+	}
+
+	httpConfig := api.HTTPConfigFromViper()
+
+	httpClient, err := api.NewHTTPClient(httpConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &influxClient{
+		url:        *u,
+		user:       viper.GetString("influxdb.user"),
+		password:   viper.GetString("influxdb.password"),
+		httpClient: httpClient,
+		retries:    httpConfig.Retries,
+	}, nil
+}
+
+func (c *influxClient) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// Query sends query to /query endpoint, error is returned for failed request
+// as well as for error reported by server for any statement
+func (c *influxClient) Query(ctx context.Context, q influx.Query) (*influx.Response, error) {
+
+	u := c.url
+	u.Path = path.Join(u.Path, "query")
+
+	values := url.Values{}
+	values.Set("q", q.Command)
+	if q.Database != "" {
+		values.Set("db", q.Database)
+	}
+	if q.Precision != "" {
+		values.Set("epoch", q.Precision)
+	}
+	if len(q.Parameters) > 0 {
+		params, err := json.Marshal(q.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		values.Set("params", string(params))
+	}
+
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", u.String(), strings.NewReader(values.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if c.user != "" {
+			req.SetBasicAuth(c.user, c.password)
+		}
+		return req, nil
+	}
+
+	retries := 0
+	if isReadOnlyInfluxQuery(q.Command) {
+		retries = c.retries
+	}
+
+	resp, err := api.DoWithRetry(ctx, c.httpClient, retries, log, newRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response influx.Response
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	err = decoder.Decode(&response)
+
+	if err != nil || (resp.StatusCode != http.StatusOK && response.Error() == nil) {
+		return nil, fmt.Errorf("InfluxDB query failed, status: %s, body: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if response.Error() != nil {
+		return nil, response.Error()
+	}
+
+	return &response, nil
+}
+
+// createInfluxDb creates new InfluxDB database
+func createInfluxDb(ctx context.Context, ic *influxClient, name string) error {
+
+	_, err := runInfluxQuery(ctx, ic, newInfluxQueryBuilder("").
+		Keyword("CREATE DATABASE").Ident(name))
+
+	return err
+}
+
+// listInfluxDbs returns names of all InfluxDB databases
+func listInfluxDbs(ctx context.Context, ic *influxClient) ([]string, error) {
+
+	response, err := runInfluxQuery(ctx, ic, newInfluxQueryBuilder("").
+		Keyword("SHOW DATABASES"))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+
+	if len(response.Results) == 0 || len(response.Results[0].Series) == 0 {
+		return result, nil
+	}
+
+	for _, value := range response.Results[0].Series[0].Values {
+		result = append(result, fmt.Sprintf("%v", value[0]))
+	}
+
+	return result, nil
+}
+
+// fetchSensorValues reads hourly means of sensor values stored in database db
+// for given time interval
+func fetchSensorValues(ctx context.Context, ic *influxClient, db, thingId string, from, to time.Time) ([]SensorValue, error) {
+
+	response, err := runInfluxQuery(ctx, ic, newInfluxQueryBuilder(db).
+		Keyword(`SELECT MEAN("value") FROM "sensor" WHERE time >=`).Param("from", from.Format(time.RFC3339)).
+		Keyword("AND time <=").Param("to", to.Format(time.RFC3339)).
+		Keyword(`AND "id" =`).Param("id", thingId).
+		Keyword("GROUP BY time(1h)"))
+	if err != nil {
+		return nil, err
+	}
+
+	return sensorValuesFromResponse(response)
+}
+
+// fetchLastSensorValues reads last n values of sensor (thing id), values are
+// returned in chronological order
+func fetchLastSensorValues(ctx context.Context, ic *influxClient, db, thingId string, n int) ([]SensorValue, error) {
+
+	response, err := runInfluxQuery(ctx, ic, newInfluxQueryBuilder(db).
+		Keyword(`SELECT "value" FROM "sensor" WHERE "id" =`).Param("id", thingId).
+		Keyword("ORDER BY time DESC LIMIT").Param("limit", n))
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := sensorValuesFromResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	// newest values come first
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+
+	return values, nil
+}
+
+// sensorValuesFromResponse decodes values of sensor from response to single
+// SELECT statement
+func sensorValuesFromResponse(response *influx.Response) ([]SensorValue, error) {
+
+	result := []SensorValue{}
+
+	if len(response.Results) == 0 || len(response.Results[0].Series) == 0 {
+		return result, nil
+	}
+
+	// we are interested in results from first statement and first entry from series
+	for _, value := range response.Results[0].Series[0].Values {
+		sensor_value, err := CreateFromInfluxResponse(value)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, *sensor_value)
+	}
+
+	return result, nil
+}
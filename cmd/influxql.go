@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	influx "github.com/influxdata/influxdb1-client/v2"
+)
+
+// quoteInfluxIdent returns InfluxQL identifier (database, measurement, tag or
+// field name) in double quotes with escaped special characters
+func quoteInfluxIdent(name string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(name) + `"`
+}
+
+// quoteInfluxString returns InfluxQL string literal in single quotes with
+// escaped special characters
+func quoteInfluxString(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`)
+	return `'` + replacer.Replace(value) + `'`
+}
+
+// influxQueryBuilder composes InfluxQL statement from keywords, quoted
+// identifiers and values. Values are sent as bound parameters, so they are
+// never interpreted as part of the statement.
+type influxQueryBuilder struct {
+	db     string
+	parts  []string
+	params map[string]interface{}
+}
+
+func newInfluxQueryBuilder(db string) *influxQueryBuilder {
+	return &influxQueryBuilder{db: db, params: map[string]interface{}{}}
+}
+
+// Keyword appends static part of statement, it must never contain values
+// provided by user
+func (b *influxQueryBuilder) Keyword(text string) *influxQueryBuilder {
+	b.parts = append(b.parts, text)
+	return b
+}
+
+// Ident appends quoted identifier, identifiers cannot be bound parameters
+func (b *influxQueryBuilder) Ident(name string) *influxQueryBuilder {
+	b.parts = append(b.parts, quoteInfluxIdent(name))
+	return b
+}
+
+// Param appends placeholder of bound parameter with given name and value
+func (b *influxQueryBuilder) Param(name string, value interface{}) *influxQueryBuilder {
+	b.parts = append(b.parts, "$"+name)
+	b.params[name] = value
+	return b
+}
+
+func (b *influxQueryBuilder) Command() string {
+	return strings.Join(b.parts, " ")
+}
+
+// String returns statement with parameters replaced by escaped literals, it
+// is used for logging only
+func (b *influxQueryBuilder) String() string {
+
+	// longer names first to avoid replacing prefix of other parameter
+	names := make([]string, 0, len(b.params))
+	for name := range b.params {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+	result := b.Command()
+	for _, name := range names {
+		literal := fmt.Sprintf("%v", b.params[name])
+		if value, ok := b.params[name].(string); ok {
+			literal = quoteInfluxString(value)
+		}
+		result = strings.Replace(result, "$"+name, literal, -1)
+	}
+
+	return result
+}
+
+func (b *influxQueryBuilder) Query() influx.Query {
+	return influx.NewQueryWithParameters(b.Command(), b.db, "", b.params)
+}
+
+// runInfluxQuery executes statement built by builder
+func runInfluxQuery(ctx context.Context, ic *influxClient, b *influxQueryBuilder) (*influx.Response, error) {
+
+	log.Debugf("query: %s", b)
+
+	response, err := ic.Query(ctx, b.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("response: %s", response)
+
+	return response, nil
+}
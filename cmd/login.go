@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"piot-cli/api"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// runLogin logs in by credentials and stores token for next invocations
+func runLogin(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+	err := client.LoginWithCredentialsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Logged in as %s (%s)\n", viper.GetString("piot.user"), viper.GetString("piot.url"))
+
+	return nil
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in and store token for next invocations",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runLogin(cmd.Context(), newApiClient(), cmd.OutOrStdout())
+		handleError(err)
+	},
+}
+
+// runLogout removes stored token
+func runLogout(client api.PiotAPI, out io.Writer) error {
+
+	err := client.Logout()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Logged out %s (%s)\n", viper.GetString("piot.user"), viper.GetString("piot.url"))
+
+	return nil
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove stored token",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runLogout(newApiClient(), cmd.OutOrStdout())
+		handleError(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+}
@@ -1,70 +1,472 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io"
 	"piot-cli/api"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+// getSelectedOrg returns organization selected by --org flag (or default org
+// of active context). Nil is returned if no organization is selected, active
+// organization of user profile is used in such case.
+func getSelectedOrg(ctx context.Context, client api.PiotAPI) (*api.Org, error) {
+
+	name := viper.GetString("org")
+	if name == "" {
+		return nil, nil
+	}
+
+	return getOrgByNameOrFail(ctx, client, name)
+}
+
+// getOrg returns organization selected by --org flag or active organization
+// of user profile
+func getOrg(ctx context.Context, client api.PiotAPI) (*api.Org, error) {
+
+	org, err := getSelectedOrg(ctx, client)
+	if err != nil || org != nil {
+		return org, err
+	}
+
+	profile, err := client.GetUserProfileContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile.GetActiveOrg()
+}
+
+// getThings fetches things of organization selected by --org flag without
+// changing active organization of user profile. Things of active organization
+// (or all things) are fetched if no organization is selected.
+func getThings(ctx context.Context, client api.PiotAPI, all bool, filter api.ThingFilterFunctionType) ([]api.Thing, error) {
+
+	org, err := getSelectedOrg(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if org == nil {
+		return client.GetThingsContext(ctx, all, filter)
+	}
+
+	return getOrgThings(ctx, client, org, filter)
+}
+
+// getOrgThings fetches things of given organization
+func getOrgThings(ctx context.Context, client api.PiotAPI, org *api.Org, filter api.ThingFilterFunctionType) ([]api.Thing, error) {
+
+	log.Debugf("Fetching things of org '%s' (%s)", org.Name, org.Id)
+
+	return client.GetOrgThingsContext(ctx, org.Id, filter)
+}
+
+// getThingParents fetches parents of things of organization selected by
+// --org flag, active organization (or all things)
+func getThingParents(ctx context.Context, client api.PiotAPI, all bool) (map[string]string, error) {
+
+	org, err := getSelectedOrg(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if org == nil {
+		return client.GetThingParentsContext(ctx, all)
+	}
+
+	return client.GetOrgThingParentsContext(ctx, org.Id)
+}
+
+// runOrg prints table of organizations, membership and active organization
+// of logged user
+func runOrg(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	profile, err := client.GetUserProfileContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	orgs, err := client.GetOrgsContext(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	fmt.Fprintf(w, "NAME\tMEMBER\tCURRENT\tINFLUXDB\t\n")
+	for i := 0; i < len(orgs); i++ {
+
+		isCurrent := ""
+		if orgs[i].Id == profile.OrgId {
+			isCurrent = "X"
+		}
+
+		isMember := ""
+		for j := 0; j < len(profile.Orgs); j++ {
+			if profile.Orgs[j].Id == orgs[i].Id {
+				isMember = "X"
+				break
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n",
+			orgs[i].Name,
+			isMember,
+			isCurrent,
+			orgs[i].InfluxDb,
+		)
+	}
+	return w.Flush()
+}
+
 var orgCmd = &cobra.Command{
 	Use:   "org",
 	Short: "Get list of organizations",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
-		client := api.NewClient(log)
+		err := runOrg(cmd.Context(), newApiClient(), cmd.OutOrStdout())
+		handleError(err)
+	},
+}
 
-		err := client.Login()
+// runOrgSet sets active organization of logged user
+func runOrgSet(ctx context.Context, client api.PiotAPI, name string) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return client.SetCurrentOrgContext(ctx, name)
+}
+
+var orgSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set current org",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runOrgSet(cmd.Context(), newApiClient(), args[0])
 		handleError(err)
+	},
+}
+
+var (
+	config_org_name               string
+	config_org_description        string
+	config_org_influxdb           string
+	config_org_provision_influxdb bool
+)
+
+// addOrgAttributeFlags registers flags for editable org attributes
+func addOrgAttributeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&config_org_description, "description", "", "organization description")
+	cmd.Flags().StringVar(&config_org_influxdb, "influxdb", "", "name of InfluxDB database of organization")
+}
+
+// getOrgAttributes builds org attributes from flags explicitly set by user
+func getOrgAttributes(cmd *cobra.Command) *api.OrgAttributes {
+
+	attrs := &api.OrgAttributes{}
+	flags := cmd.Flags()
+
+	if flags.Changed("name") {
+		attrs.Name = &config_org_name
+	}
+	if flags.Changed("description") {
+		attrs.Description = &config_org_description
+	}
+	if flags.Changed("influxdb") {
+		attrs.InfluxDb = &config_org_influxdb
+	}
 
-		profile, err := client.GetUserProfile()
+	return attrs
+}
+
+// getOrgByNameOrFail returns organization or error if it doesn't exist
+func getOrgByNameOrFail(ctx context.Context, client api.PiotAPI, name string) (*api.Org, error) {
+
+	org, err := client.GetOrgByNameContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if org == nil {
+		return nil, fmt.Errorf("Organization '%s' does not exist", name)
+	}
+
+	return org, nil
+}
+
+// runOrgCreate creates organization and optionally provisions its InfluxDB
+// database (--provision-influxdb flag)
+func runOrgCreate(ctx context.Context, client api.PiotAPI, out io.Writer, attrs *api.OrgAttributes) error {
+
+	// database name is derived from org name if not set explicitly
+	if config_org_provision_influxdb && (attrs.InfluxDb == nil || *attrs.InfluxDb == "") {
+		db := strings.ToLower(*attrs.Name)
+		attrs.InfluxDb = &db
+	}
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	org, err := createOrg(ctx, client, attrs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Organization '%s' created (%s)\n", org.Name, org.Id)
+
+	if config_org_provision_influxdb {
+		err = provisionInfluxDb(ctx, org.InfluxDb)
+		if err != nil {
+			return fmt.Errorf("Organization '%s' created, but provisioning of InfluxDB database '%s' failed: %v", org.Name, org.InfluxDb, err)
+		}
+
+		fmt.Fprintf(out, "InfluxDB database '%s' created\n", org.InfluxDb)
+	}
+
+	return nil
+}
+
+var orgCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Create new organization",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		attrs := getOrgAttributes(cmd)
+		attrs.Name = &args[0]
+
+		err := runOrgCreate(cmd.Context(), newApiClient(), cmd.OutOrStdout(), attrs)
 		handleError(err)
+	},
+}
+
+// createOrg creates organization and adds logged user to its members, new
+// organization could be set as active organization of logged user then
+func createOrg(ctx context.Context, client api.PiotAPI, attrs *api.OrgAttributes) (*api.Org, error) {
+
+	org, err := client.CreateOrgContext(ctx, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := client.GetUserProfileContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Organization '%s' created, but logged user could not be added to its members: %v", org.Name, err)
+	}
+
+	user, err := getUserByEmailOrFail(ctx, client, profile.Email)
+	if err == nil {
+		err = client.AddOrgMemberContext(ctx, org.Id, user.Id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Organization '%s' created, but logged user could not be added to its members: %v", org.Name, err)
+	}
+
+	return org, nil
+}
+
+// provisionInfluxDb creates InfluxDB database of organization
+func provisionInfluxDb(ctx context.Context, db string) error {
+
+	ic, err := newInfluxClient()
+	if err != nil {
+		return err
+	}
+	defer ic.Close()
+
+	return createInfluxDb(ctx, ic, db)
+}
+
+// runOrgUpdate changes attributes of organization
+func runOrgUpdate(ctx context.Context, client api.PiotAPI, out io.Writer, name string, attrs *api.OrgAttributes) error {
+
+	if attrs.Name == nil && attrs.Description == nil && attrs.InfluxDb == nil {
+		return fmt.Errorf("Nothing to update, try to run command with -h flag to see supported attributes")
+	}
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	org, err := getOrgByNameOrFail(ctx, client, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.UpdateOrgContext(ctx, org.Id, attrs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Organization '%s' updated\n", name)
 
-		orgs, err := client.GetOrgs(nil)
+	return nil
+}
+
+var orgUpdateCmd = &cobra.Command{
+	Use:   "update NAME",
+	Short: "Update attributes of organization",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runOrgUpdate(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args[0], getOrgAttributes(cmd))
 		handleError(err)
+	},
+}
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, OUTPUT_PADDING, ' ', 0)
-		fmt.Fprintf(w, "NAME\tMEMBER\tCURRENT\tINFLUXDB\t\n")
-		for i := 0; i < len(orgs); i++ {
+// runOrgDelete deletes organization, deletion is confirmed by user unless
+// --yes flag is set
+func runOrgDelete(ctx context.Context, client api.PiotAPI, out io.Writer, name string) error {
 
-			isCurrent := ""
-			if orgs[i].Id == profile.OrgId {
-				isCurrent = "X"
-			}
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
 
-			isMember := ""
-			for j := 0; j < len(profile.Orgs); j++ {
-				if profile.Orgs[j].Id == orgs[i].Id {
-					isMember = "X"
-					break
-				}
-			}
+	org, err := getOrgByNameOrFail(ctx, client, name)
+	if err != nil {
+		return err
+	}
 
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n",
-				orgs[i].Name,
-				isMember,
-				isCurrent,
-				orgs[i].InfluxDb,
-			)
-		}
-		w.Flush()
+	if !config_yes && !askForConfirmation(out, fmt.Sprintf("Delete organization '%s' (%s)?", org.Name, org.Id)) {
+		return nil
+	}
+
+	err = client.DeleteOrgContext(ctx, org.Id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Organization '%s' deleted\n", org.Name)
+
+	return nil
+}
+
+var orgDeleteCmd = &cobra.Command{
+	Use:   "delete NAME",
+	Short: "Delete organization",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runOrgDelete(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args[0])
+		handleError(err)
 	},
 }
 
-var orgSetCmd = &cobra.Command{
-	Use:   "set",
-	Short: "Set current org",
+var orgMembersCmd = &cobra.Command{
+	Use:   "members",
+	Short: "Manage members of organization (selected by --org flag or active one)",
+	Long:  ``,
+}
+
+// runOrgMembersList prints table of members of organization
+func runOrgMembersList(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	org, err := getOrg(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	users, err := client.GetOrgMembersContext(ctx, org.Id)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	fmt.Fprintf(w, "EMAIL\tID\t\n")
+	for i := 0; i < len(users); i++ {
+		fmt.Fprintf(w, "%s\t%s\t\n", users[i].Email, users[i].Id)
+	}
+	return w.Flush()
+}
+
+var orgMembersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List members of organization",
 	Long:  ``,
-	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		client := api.NewClient(log)
+		err := runOrgMembersList(cmd.Context(), newApiClient(), cmd.OutOrStdout())
+		handleError(err)
+	},
+}
+
+// changeOrgMembers adds or removes users identified by emails
+func changeOrgMembers(ctx context.Context, client api.PiotAPI, out io.Writer, emails []string, add bool) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	org, err := getOrg(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	for _, email := range emails {
+		user, err := getUserByEmailOrFail(ctx, client, email)
+		if err != nil {
+			return err
+		}
 
-		err := client.Login()
+		if add {
+			err = client.AddOrgMemberContext(ctx, org.Id, user.Id)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "User '%s' added to organization '%s'\n", email, org.Name)
+		} else {
+			err = client.RemoveOrgMemberContext(ctx, org.Id, user.Id)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "User '%s' removed from organization '%s'\n", email, org.Name)
+		}
+	}
+
+	return nil
+}
+
+var orgMembersAddCmd = &cobra.Command{
+	Use:   "add EMAIL...",
+	Short: "Add users to organization",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := changeOrgMembers(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args, true)
 		handleError(err)
+	},
+}
 
-		err = client.SetCurrentOrg(args[0])
+var orgMembersRemoveCmd = &cobra.Command{
+	Use:   "remove EMAIL...",
+	Short: "Remove users from organization",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := changeOrgMembers(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args, false)
 		handleError(err)
 	},
 }
@@ -72,6 +474,22 @@ var orgSetCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(orgCmd)
 
+	orgCmd.AddCommand(orgCreateCmd)
+	addOrgAttributeFlags(orgCreateCmd)
+	orgCreateCmd.Flags().BoolVar(&config_org_provision_influxdb, "provision-influxdb", false, "create InfluxDB database for organization (name derived from org name if --influxdb is not set)")
+
+	orgCmd.AddCommand(orgUpdateCmd)
+	addOrgAttributeFlags(orgUpdateCmd)
+	orgUpdateCmd.Flags().StringVar(&config_org_name, "name", "", "new organization name")
+
+	orgCmd.AddCommand(orgDeleteCmd)
+	orgDeleteCmd.Flags().BoolVarP(&config_yes, "yes", "y", false, "do not ask for confirmation")
+
+	orgCmd.AddCommand(orgMembersCmd)
+	orgMembersCmd.AddCommand(orgMembersListCmd)
+	orgMembersCmd.AddCommand(orgMembersAddCmd)
+	orgMembersCmd.AddCommand(orgMembersRemoveCmd)
+
 	orgCmd.AddCommand(orgSetCmd)
 	//orgSetCmd.Flags().StringVar(&config_org, "org", "", "Organization")
 	//orgSetCmd.MarkFlagRequired("org")
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"piot-cli/api"
+	"piot-cli/apitest"
+
+	"github.com/spf13/viper"
+)
+
+func TestCreateOrgAddsLoggedUser(t *testing.T) {
+
+	ctx := context.Background()
+	server, client := newTestServer(t)
+
+	name := "CUSTOMER"
+	org, err := createOrg(ctx, client, &api.OrgAttributes{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := server.Users()
+	if len(users) != 1 || len(users[0].Orgs) != 1 || users[0].Orgs[0].Id != org.Id {
+		t.Fatalf("logged user is not member of created organization: %+v", users)
+	}
+
+	// new organization could be activated (e.g. by org set)
+	if err := client.SetCurrentOrgContext(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateOrgInvalid(t *testing.T) {
+
+	server, client := newTestServer(t)
+
+	_, err := createOrg(context.Background(), client, &api.OrgAttributes{})
+	if err == nil {
+		t.Fatal("expected error for organization without name")
+	}
+
+	if orgs := server.Orgs(); len(orgs) != 0 {
+		t.Errorf("unexpected organizations %+v", orgs)
+	}
+}
+
+func TestProvisionInfluxDb(t *testing.T) {
+
+	influx := newTestInfluxServer(t)
+
+	if err := provisionInfluxDb(context.Background(), "customer"); err != nil {
+		t.Fatal(err)
+	}
+
+	dbs := influx.Databases()
+	if len(dbs) != 1 || dbs[0] != "customer" {
+		t.Errorf("expected database 'customer', got %v", dbs)
+	}
+}
+
+// seedOrgs fills fake server by organizations with members, logged user is
+// member of HOME (active) and COTTAGE, not of CUSTOMER
+func seedOrgs(server *apitest.Server) {
+
+	home := server.AddOrg(api.Org{Name: "HOME", Description: "Family house", InfluxDb: "home"})
+	server.AddOrg(api.Org{Name: "COTTAGE", InfluxDb: "cottage"})
+
+	customer := server.AddOrg(api.Org{Name: "CUSTOMER"})
+
+	admin := server.Users()[0]
+	server.RemoveOrgMember(customer.Id, admin.Id)
+
+	alice := server.AddUser(api.User{Email: "alice@example.com"}, "alice")
+	bob := server.AddUser(api.User{Email: "bob@example.com"}, "bob")
+	server.AddOrgMember(home.Id, alice.Id)
+	server.AddOrgMember(customer.Id, bob.Id)
+}
+
+// listOrgsAfter runs command and prints listing of organizations then
+func listOrgsAfter(run func(ctx context.Context, client api.PiotAPI, out io.Writer) error) func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+	return func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+		if err := run(ctx, client, out); err != nil {
+			return err
+		}
+		return runOrg(ctx, client, out)
+	}
+}
+
+func TestOrgCommands(t *testing.T) {
+
+	runCommandCases(t, seedOrgs, []commandCase{
+		{
+			name: "org",
+			run:  runOrg,
+		},
+		{
+			name: "org-set",
+			run: listOrgsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runOrgSet(ctx, client, "COTTAGE")
+			}),
+		},
+		{
+			name: "org-set-not-member",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runOrgSet(ctx, client, "CUSTOMER")
+			},
+			err: "not member",
+		},
+		{
+			name: "org-set-missing",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runOrgSet(ctx, client, "MISSING")
+			},
+			err: "Organization 'MISSING' does not exist",
+		},
+		{
+			name: "org-create",
+			run: listOrgsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				name := "SHOP"
+				description := "Shop in town"
+				if err := runOrgCreate(ctx, client, out, &api.OrgAttributes{Name: &name, Description: &description}); err != nil {
+					return err
+				}
+				// creator is member of new organization
+				return runOrgSet(ctx, client, name)
+			}),
+		},
+		{
+			name: "org-update",
+			run: listOrgsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				name := "COTTAGE2"
+				db := "cottage2"
+				return runOrgUpdate(ctx, client, out, "COTTAGE", &api.OrgAttributes{Name: &name, InfluxDb: &db})
+			}),
+		},
+		{
+			name: "org-update-nothing",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runOrgUpdate(ctx, client, out, "COTTAGE", &api.OrgAttributes{})
+			},
+			err: "Nothing to update",
+		},
+		{
+			name:  "org-delete",
+			setup: func(*apitest.Server) { config_yes = true },
+			run: listOrgsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runOrgDelete(ctx, client, out, "CUSTOMER")
+			}),
+		},
+		{
+			name: "org-members",
+			run:  runOrgMembersList,
+		},
+		{
+			name:  "org-members-selected",
+			setup: func(*apitest.Server) { viper.Set("org", "CUSTOMER") },
+			run:   runOrgMembersList,
+		},
+		{
+			name: "org-members-add",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				err := changeOrgMembers(ctx, client, out, []string{"bob@example.com"}, true)
+				if err != nil {
+					return err
+				}
+				return runOrgMembersList(ctx, client, out)
+			},
+		},
+		{
+			name: "org-members-remove",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				err := changeOrgMembers(ctx, client, out, []string{"alice@example.com"}, false)
+				if err != nil {
+					return err
+				}
+				return runOrgMembersList(ctx, client, out)
+			},
+		},
+		{
+			name: "org-members-add-missing",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return changeOrgMembers(ctx, client, out, []string{"missing@example.com"}, true)
+			},
+			err: "User 'missing@example.com' does not exist",
+		},
+	})
+}
+
+func TestRunOrgCreateProvision(t *testing.T) {
+
+	ctx := context.Background()
+	server, client := newTestServer(t)
+	influx := newTestInfluxServer(t)
+
+	config_org_provision_influxdb = true
+
+	name := "Shop"
+	err := runOrgCreate(ctx, client, ioutil.Discard, &api.OrgAttributes{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orgs := server.Orgs()
+	if len(orgs) != 1 || orgs[0].InfluxDb != "shop" {
+		t.Fatalf("expected organization with database 'shop', got %+v", orgs)
+	}
+
+	if dbs := influx.Databases(); len(dbs) != 1 || dbs[0] != "shop" {
+		t.Errorf("expected database 'shop', got %v", dbs)
+	}
+}
+
+func TestRunOrgCreateProvisionFailure(t *testing.T) {
+
+	ctx := context.Background()
+	server, client := newTestServer(t)
+
+	// nothing listens on closed server
+	influx := newTestInfluxServer(t)
+	influx.Close()
+
+	config_org_provision_influxdb = true
+
+	name := "SHOP"
+	err := runOrgCreate(ctx, client, ioutil.Discard, &api.OrgAttributes{Name: &name})
+	if err == nil || !strings.Contains(err.Error(), "Organization 'SHOP' created, but provisioning of InfluxDB database 'shop' failed") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// organization is kept
+	if orgs := server.Orgs(); len(orgs) != 1 || orgs[0].Name != "SHOP" {
+		t.Errorf("expected organization SHOP, got %+v", orgs)
+	}
+}
@@ -1,35 +1,205 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"piot-cli/api"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	config_profile_email      string
+	config_profile_active_org string
+)
+
+func printProfile(out io.Writer, profile api.UserProfile) error {
+
+	profileJson, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%s\n", string(profileJson))
+
+	return nil
+}
+
+// offerConfigUpdate asks user if value of configuration key stored in config
+// file should be replaced by new value, nothing happens if key is not stored
+// in config file (e.g. it is set by flag or environment variable)
+func offerConfigUpdate(out io.Writer, key string, value string, question string) error {
+
+	f, err := loadConfigFile()
+	if err != nil {
+		log.Warningf("Cannot read config file: %v", err)
+		return nil
+	}
+
+	if !f.updateStoredValue(key, value) {
+		log.Debugf("Key '%s' is not stored in config file '%s'", key, f.path)
+		return nil
+	}
+
+	if !askForConfirmation(out, fmt.Sprintf("%s in '%s'?", question, f.path)) {
+		return nil
+	}
+
+	err = f.save()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Config file '%s' updated\n", f.path)
+
+	return nil
+}
+
+// runProfile prints profile of logged user
+func runProfile(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	profile, err := client.GetUserProfileContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printProfile(out, profile)
+}
+
 var profileCmd = &cobra.Command{
 	Use:   "profile",
 	Short: "Get user profile",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
-		client := api.NewClient(log)
-
-		err := client.Login()
+		err := runProfile(cmd.Context(), newApiClient(), cmd.OutOrStdout())
 		handleError(err)
+	},
+}
 
-		profile, err := client.GetUserProfile()
-		handleError(err)
+// runProfileUpdate changes email or active organization (activeOrg is name of
+// organization, empty if not changed) of logged user
+func runProfileUpdate(ctx context.Context, client api.PiotAPI, out io.Writer, attrs *api.UserProfileAttributes, activeOrg string) error {
 
-		profileJson, err := json.MarshalIndent(profile, "", "  ")
-		handleError(err)
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if activeOrg != "" {
+		org, err := getOrgByNameOrFail(ctx, client, activeOrg)
+		if err != nil {
+			return err
+		}
+		attrs.OrgId = &org.Id
+	}
+
+	if attrs.Email == nil && attrs.OrgId == nil {
+		return fmt.Errorf("Nothing to update, use --email or --active-org flags")
+	}
 
-		fmt.Printf("%s\n", string(profileJson))
+	profile, err := client.UpdateUserProfileContext(ctx, attrs)
+	if err != nil {
+		return err
+	}
+
+	err = printProfile(out, profile)
+	if err != nil {
+		return err
+	}
+
+	if attrs.Email != nil {
+		return offerConfigUpdate(out, "piot.user", *attrs.Email, "Update user stored")
+	}
+
+	return nil
+}
+
+var profileUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update fields of user profile",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		attrs := &api.UserProfileAttributes{}
+		if cmd.Flags().Changed("email") {
+			attrs.Email = &config_profile_email
+		}
+
+		active_org := ""
+		if cmd.Flags().Changed("active-org") {
+			active_org = config_profile_active_org
+		}
+
+		err := runProfileUpdate(cmd.Context(), newApiClient(), cmd.OutOrStdout(), attrs, active_org)
+		handleError(err)
 	},
 }
 
-//{"query":"mutation {updateUserProfile(profile: {org_id: \"5e1437163afe8695f1351311\"}) {is_admin, email, org_id, orgs {id, name}}}"}
+// runProfilePassword changes password of logged user, current and new
+// passwords are read from terminal
+func runProfilePassword(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// stored password is not used, it could belong to other account or be
+	// outdated and password change must be confirmed by user anyway
+	current, err := readPassword("Current password: ")
+	if err != nil {
+		return err
+	}
+
+	password, err := askForNewPassword()
+	if err != nil {
+		return err
+	}
+
+	err = client.ChangePasswordContext(ctx, current, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Password changed")
+
+	err = offerConfigUpdate(out, "piot.password", password, "Update password stored")
+	if err != nil {
+		return err
+	}
+
+	// cached token could be invalidated by password change
+	if askForConfirmation(out, "Login with new password and update token cache?") {
+		return client.LoginWithCredentialsContext(ctx)
+	}
+
+	return nil
+}
+
+var profilePasswordCmd = &cobra.Command{
+	Use:   "password",
+	Short: "Change password",
+	Long: `Change password of logged user. New password is read from terminal twice
+(without echo). Stored credentials (config file and token cache) could be
+updated after the change.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runProfilePassword(cmd.Context(), newApiClient(), cmd.OutOrStdout())
+		handleError(err)
+	},
+}
 
 func init() {
 	rootCmd.AddCommand(profileCmd)
+
+	profileCmd.AddCommand(profileUpdateCmd)
+	profileUpdateCmd.Flags().StringVar(&config_profile_email, "email", "", "new email (login) of user")
+	profileUpdateCmd.Flags().StringVar(&config_profile_active_org, "active-org", "", "name of organization to be set as active")
+
+	profileCmd.AddCommand(profilePasswordCmd)
 }
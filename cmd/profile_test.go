@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"piot-cli/api"
+
+	"github.com/spf13/viper"
+)
+
+func TestProfileCommands(t *testing.T) {
+
+	updateActiveOrg := func(name string) func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+		return func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+			return runProfileUpdate(ctx, client, out, &api.UserProfileAttributes{}, name)
+		}
+	}
+
+	runCommandCases(t, seedOrgs, []commandCase{
+		{
+			name: "profile",
+			run:  runProfile,
+		},
+		{
+			name: "profile-update-active-org",
+			run:  updateActiveOrg("COTTAGE"),
+		},
+		{
+			name: "profile-update-not-member",
+			run:  updateActiveOrg("CUSTOMER"),
+			err:  "not member",
+		},
+		{
+			name: "profile-update-missing-org",
+			run:  updateActiveOrg("MISSING"),
+			err:  "Organization 'MISSING' does not exist",
+		},
+		{
+			name: "profile-update-nothing",
+			run:  updateActiveOrg(""),
+			err:  "Nothing to update",
+		},
+	})
+}
+
+func TestRunProfileUpdateEmail(t *testing.T) {
+
+	ctx := context.Background()
+	server, client := newTestServer(t)
+
+	path := writeTestConfig(t, testConfig)
+	viper.SetConfigFile(path)
+	defer viper.SetConfigFile("")
+
+	// user confirms update of config file
+	stdin := stdinReader
+	stdinReader = bufio.NewReader(strings.NewReader("y\n"))
+	defer func() { stdinReader = stdin }()
+
+	email := "root@example.com"
+
+	var out bytes.Buffer
+	err := runProfileUpdate(ctx, client, &out, &api.UserProfileAttributes{Email: &email}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContains(t, out.String(),
+		`"email": "root@example.com"`,
+		"Update user stored in '"+path+"'? [y/N]: ",
+		"Config file '"+path+"' updated",
+	)
+
+	if server.User != email {
+		t.Errorf("email of logged user not changed: %s", server.User)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(content), "user: root@example.com", "# my comment")
+}
+
+func TestRunProfilePasswordReadsCurrentPassword(t *testing.T) {
+
+	ctx := context.Background()
+	server, client := newTestServer(t)
+
+	// stored password is not used as current password
+	useTestConfig(t, "---\npiot:\n  password: stored-password\n")
+
+	// current password, new password twice, config and token cache are not
+	// updated
+	stdin := stdinReader
+	stdinReader = bufio.NewReader(strings.NewReader(server.Password + "\nnew-secret\nnew-secret\nn\nn\n"))
+	defer func() { stdinReader = stdin }()
+
+	var out bytes.Buffer
+	err := runProfilePassword(ctx, client, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContains(t, out.String(), "Password changed")
+
+	if server.Password != "new-secret" {
+		t.Errorf("expected changed password, got %q", server.Password)
+	}
+}
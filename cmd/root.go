@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"piot-cli/api"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/op/go-logging"
@@ -30,10 +35,21 @@ var (
 	config_influxdb_url      string
 	config_influxdb_user     string
 	config_influxdb_password string
-
-//	config_org       string
+	config_org               string
+	config_timeout           time.Duration
+	config_connect_timeout   time.Duration
+	config_retries           int
+	config_ca_file           string
+	config_cert_file         string
+	config_key_file          string
+	config_insecure          bool
+	config_proxy             string
 )
 
+// grace period for command to finish after interrupt (e.g. when it waits for
+// user input, which cannot be cancelled)
+const INTERRUPT_GRACE_PERIOD = 2 * time.Second
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:     "piot-cli",
@@ -48,12 +64,43 @@ var log = logging.MustGetLogger(LOGGER_MODULE)
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+
+	ctx, cancel := newInterruptContext()
+	defer cancel()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
+// newInterruptContext returns context which is cancelled by SIGINT or SIGTERM,
+// in-flight requests are aborted and command exits. Second signal terminates
+// process immediately.
+func newInterruptContext() (context.Context, context.CancelFunc) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			log.Warning("Interrupted, cancelling requests")
+			signal.Stop(signals)
+			cancel()
+			time.AfterFunc(INTERRUPT_GRACE_PERIOD, func() { os.Exit(130) })
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -62,12 +109,24 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&config_piot_user, "piot-user", "", "User")
 	rootCmd.PersistentFlags().StringVar(&config_piot_password, "piot-password", "", "Password")
 	rootCmd.PersistentFlags().StringVarP(&config_log_level, "log-level", "", "INFO", "Log level (CRITICIAL, ERROR, WARNING, NOTICE, INFO, DEBUG)")
-	//	rootCmd.PersistentFlags().StringVar(&config_org, "org", "", "Organization")
+	rootCmd.PersistentFlags().StringVar(&config_context, "context", "", "Named context from config file (default is current-context)")
+	rootCmd.PersistentFlags().StringVar(&config_org, "org", "", "Organization to work with (default is active org of user profile)")
 
 	rootCmd.PersistentFlags().StringVar(&config_influxdb_url, "influxdb-url", "", "InfluxDB URL")
 	rootCmd.PersistentFlags().StringVar(&config_influxdb_user, "influxdb-user", "", "InfluxDB User")
 	rootCmd.PersistentFlags().StringVar(&config_influxdb_password, "influxdb-password", "", "InfluxDB Password")
 
+	rootCmd.PersistentFlags().DurationVar(&config_timeout, "timeout", api.DEFAULT_TIMEOUT, "Timeout of requests to PIOT API and InfluxDB (0 means no timeout)")
+	rootCmd.PersistentFlags().DurationVar(&config_connect_timeout, "connect-timeout", api.DEFAULT_CONNECT_TIMEOUT, "Timeout of connecting to PIOT API and InfluxDB (0 means no timeout)")
+
+	rootCmd.PersistentFlags().IntVar(&config_retries, "retries", api.DEFAULT_RETRIES, "Number of retries of read-only requests which failed for transient reason (network error, 5xx or 429 status)")
+
+	rootCmd.PersistentFlags().StringVar(&config_ca_file, "ca-file", "", "Bundle of trusted CA certificates (PEM) for PIOT API and InfluxDB")
+	rootCmd.PersistentFlags().StringVar(&config_cert_file, "cert-file", "", "Client certificate (PEM) for PIOT API and InfluxDB")
+	rootCmd.PersistentFlags().StringVar(&config_key_file, "key-file", "", "Private key (PEM) of client certificate")
+	rootCmd.PersistentFlags().BoolVar(&config_insecure, "insecure-skip-verify", false, "Do not verify certificates of PIOT API and InfluxDB (insecure)")
+	rootCmd.PersistentFlags().StringVar(&config_proxy, "proxy", "", "HTTP proxy url (default is taken from HTTP_PROXY, HTTPS_PROXY and NO_PROXY)")
+
 	viper.BindPFlag("piot.url", rootCmd.PersistentFlags().Lookup("piot-url"))
 	viper.BindPFlag("piot.user", rootCmd.PersistentFlags().Lookup("piot-user"))
 	viper.BindPFlag("piot.password", rootCmd.PersistentFlags().Lookup("piot-password"))
@@ -75,7 +134,15 @@ func init() {
 	viper.BindPFlag("influxdb.url", rootCmd.PersistentFlags().Lookup("influxdb-url"))
 	viper.BindPFlag("influxdb.user", rootCmd.PersistentFlags().Lookup("influxdb-user"))
 	viper.BindPFlag("influxdb.password", rootCmd.PersistentFlags().Lookup("influxdb-password"))
-	//	viper.BindPFlag("org", rootCmd.PersistentFlags().Lookup("org"))
+	viper.BindPFlag("org", rootCmd.PersistentFlags().Lookup("org"))
+	viper.BindPFlag("http.timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("http.connect_timeout", rootCmd.PersistentFlags().Lookup("connect-timeout"))
+	viper.BindPFlag("http.retries", rootCmd.PersistentFlags().Lookup("retries"))
+	viper.BindPFlag("http.proxy", rootCmd.PersistentFlags().Lookup("proxy"))
+	viper.BindPFlag("tls.ca_file", rootCmd.PersistentFlags().Lookup("ca-file"))
+	viper.BindPFlag("tls.cert_file", rootCmd.PersistentFlags().Lookup("cert-file"))
+	viper.BindPFlag("tls.key_file", rootCmd.PersistentFlags().Lookup("key-file"))
+	viper.BindPFlag("tls.insecure_skip_verify", rootCmd.PersistentFlags().Lookup("insecure-skip-verify"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -130,4 +197,18 @@ func initConfig() {
 	if len(configFileUsed) > 0 {
 		log.Infof("Using config file: '%s'", configFileUsed)
 	}
+
+	// apply values of active context before any api client is created, broken
+	// current-context must not block commands (e.g. context use)
+	if err := resolveContext(); err != nil {
+		if config_context != "" {
+			log.Fatal(err)
+		}
+		log.Warning(err)
+	}
+
+	if viper.GetBool("tls.insecure_skip_verify") {
+		log.Warning("!!! TLS CERTIFICATE VERIFICATION IS DISABLED (insecure-skip-verify) !!!")
+		log.Warning("Connections to PIOT API and InfluxDB are not protected against man-in-the-middle attacks")
+	}
 }
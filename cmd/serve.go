@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"piot-cli/api"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	config_listen        string
+	config_poll_interval time.Duration
+)
+
+// metricsCollector periodically polls PIOT server and keeps last snapshot of
+// things, which is rendered in Prometheus text format on every scrape
+type metricsCollector struct {
+	client api.PiotAPI
+
+	mutex   sync.Mutex
+	org     string
+	things  []api.Thing
+	up      bool
+	updated time.Time
+}
+
+// fetch things of selected or active organization, expired token is renewed
+// by client
+func (m *metricsCollector) fetch(ctx context.Context) (string, []api.Thing, error) {
+
+	org, err := getOrg(ctx, m.client)
+	if err != nil {
+		return "", nil, err
+	}
+
+	things, err := getThings(ctx, m.client, false, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return org.Name, things, nil
+}
+
+func (m *metricsCollector) update(ctx context.Context) {
+
+	org, things, err := m.fetch(ctx)
+
+	// poll interrupted by shutdown
+	if ctx.Err() != nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err != nil {
+		log.Errorf("Failed to fetch things: %v", err)
+		m.up = false
+		return
+	}
+
+	log.Debugf("Fetched %d things of org '%s'", len(things), org)
+
+	m.org = org
+	m.things = things
+	m.up = true
+	m.updated = time.Now()
+}
+
+// poll updates snapshot of things periodically until ctx is cancelled
+func (m *metricsCollector) poll(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.update(ctx)
+		}
+	}
+}
+
+// escapeLabelValue escapes label value according to Prometheus text format
+func escapeLabelValue(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return replacer.Replace(value)
+}
+
+func boolToMetric(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func (m *metricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var buf bytes.Buffer
+	now := time.Now()
+
+	fmt.Fprintf(&buf, "# HELP piot_up Whether last poll of PIOT server was successful.\n")
+	fmt.Fprintf(&buf, "# TYPE piot_up gauge\n")
+	fmt.Fprintf(&buf, "piot_up %d\n", boolToMetric(m.up))
+
+	fmt.Fprintf(&buf, "# HELP piot_last_update_timestamp_seconds Time of last successful poll of PIOT server.\n")
+	fmt.Fprintf(&buf, "# TYPE piot_last_update_timestamp_seconds gauge\n")
+	fmt.Fprintf(&buf, "piot_last_update_timestamp_seconds %d\n", m.updated.Unix())
+
+	labels := make([]string, len(m.things))
+	for i, thing := range m.things {
+		labels[i] = fmt.Sprintf(`org="%s",name="%s",alias="%s",type="%s",class="%s",unit="%s"`,
+			escapeLabelValue(m.org),
+			escapeLabelValue(thing.Name),
+			escapeLabelValue(thing.Alias),
+			escapeLabelValue(thing.Type),
+			escapeLabelValue(thing.Sensor.Class),
+			escapeLabelValue(thing.Sensor.Unit),
+		)
+	}
+
+	fmt.Fprintf(&buf, "# HELP piot_thing_value Current sensor value.\n")
+	fmt.Fprintf(&buf, "# TYPE piot_thing_value gauge\n")
+	for i, thing := range m.things {
+		// things without numeric value (e.g. devices) are skipped
+		if value, err := strconv.ParseFloat(thing.Sensor.Value, 64); err == nil {
+			fmt.Fprintf(&buf, "piot_thing_value{%s} %v\n", labels[i], value)
+		}
+	}
+
+	fmt.Fprintf(&buf, "# HELP piot_thing_last_seen_seconds Seconds since thing was seen last time.\n")
+	fmt.Fprintf(&buf, "# TYPE piot_thing_last_seen_seconds gauge\n")
+	for i, thing := range m.things {
+		// age of thing which was never seen is unknown
+		if thing.LastSeen == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "piot_thing_last_seen_seconds{%s} %d\n", labels[i], now.Unix()-int64(thing.LastSeen))
+	}
+
+	fmt.Fprintf(&buf, "# HELP piot_thing_enabled Whether thing is enabled.\n")
+	fmt.Fprintf(&buf, "# TYPE piot_thing_enabled gauge\n")
+	for i, thing := range m.things {
+		fmt.Fprintf(&buf, "piot_thing_enabled{%s} %d\n", labels[i], boolToMetric(thing.Enabled))
+	}
+
+	fmt.Fprintf(&buf, "# HELP piot_thing_overdue Whether thing was not seen within its last seen interval.\n")
+	fmt.Fprintf(&buf, "# TYPE piot_thing_overdue gauge\n")
+	for i := range m.things {
+		fmt.Fprintf(&buf, "piot_thing_overdue{%s} %d\n", labels[i], boolToMetric(isThingOverdue(&m.things[i])))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run long living services",
+	Long:  ``,
+}
+
+// runServeMetrics polls things and serves them as Prometheus metrics until
+// ctx is cancelled
+func runServeMetrics(ctx context.Context, client api.PiotAPI) error {
+
+	if config_poll_interval <= 0 {
+		return fmt.Errorf("Invalid poll interval: %s", config_poll_interval)
+	}
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	collector := &metricsCollector{client: client}
+	collector.update(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", collector)
+	server := &http.Server{Addr: config_listen, Handler: mux}
+
+	go collector.poll(ctx, config_poll_interval)
+
+	go func() {
+		<-ctx.Done()
+		log.Info("Shutting down metrics server")
+		server.Shutdown(context.Background())
+	}()
+
+	log.Infof("Serving metrics on %s/metrics (poll interval %s)", config_listen, config_poll_interval)
+
+	err = server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+var serveMetricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Expose state of things as Prometheus metrics",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runServeMetrics(cmd.Context(), newApiClient())
+		handleError(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.AddCommand(serveMetricsCmd)
+	serveMetricsCmd.Flags().StringVar(&config_listen, "listen", ":9102", "address to listen on")
+	serveMetricsCmd.Flags().DurationVarP(&config_poll_interval, "interval", "n", 30*time.Second, "interval of polling PIOT server")
+}
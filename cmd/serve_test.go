@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"piot-cli/api"
+)
+
+func TestMetricsSkipLastSeenOfNeverSeenThings(t *testing.T) {
+
+	server, client := newTestServer(t)
+
+	org := server.AddOrg(api.Org{Name: "HOME"})
+	server.AddThing(api.Thing{Name: "seen", Type: "sensor", LastSeen: int32(time.Now().Unix() - 30), OrgId: org.Id})
+	server.AddThing(api.Thing{Name: "never", Type: "sensor", OrgId: org.Id})
+
+	collector := &metricsCollector{client: client}
+	collector.update(context.Background())
+
+	recorder := httptest.NewRecorder()
+	collector.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	metrics := recorder.Body.String()
+
+	assertContains(t, metrics, `piot_thing_last_seen_seconds{org="HOME",name="seen"`, `piot_thing_enabled{org="HOME",name="never"`)
+	assertNotContains(t, metrics, `piot_thing_last_seen_seconds{org="HOME",name="never"`)
+}
+
+func TestMetricsPollStopsOnCancel(t *testing.T) {
+
+	server, client := newTestServer(t)
+	server.AddOrg(api.Org{Name: "HOME"})
+
+	collector := &metricsCollector{client: client}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		collector.poll(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("polling not stopped after cancellation")
+	}
+}
+
+func TestServeMetricsStopsOnCancel(t *testing.T) {
+
+	server, client := newTestServer(t)
+	server.AddOrg(api.Org{Name: "HOME"})
+
+	config_listen = "127.0.0.1:0"
+	config_poll_interval = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() { done <- runServeMetrics(ctx, client) }()
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server not stopped after cancellation")
+	}
+}
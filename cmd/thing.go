@@ -1,125 +1,659 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io"
+	"path/filepath"
 	"piot-cli/api"
+	"regexp"
 	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
 	config_all        bool
 	config_thing_type string
 	config_long       bool
-)
+	config_yes        bool
+	config_dry_run    bool
+	config_regex      bool
+	config_history    int
+	config_tree       bool
+	config_columns    string
+	config_sort_by    string
+	config_reverse    bool
+	config_filter     string
 
-var thingCmd = &cobra.Command{
-	Use:   "thing",
-	Short: "Get list of things",
-	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
-		client := api.NewClient(log)
+	config_thing_alias              string
+	config_thing_enabled            bool
+	config_thing_last_seen_interval int32
+	config_thing_store_influxdb     bool
+	config_thing_store_mysqldb      bool
+	config_thing_class              string
+	config_thing_unit               string
+)
 
-		err := client.Login()
-		handleError(err)
+// newThingPatternFilter creates filter function matching things which name or
+// alias matches at least one of patterns. Patterns are shell globs (e.g.
+// B30*-Temp*) or regular expressions if regex is true.
+func newThingPatternFilter(patterns []string, regex bool) (api.ThingFilterFunctionType, error) {
 
-		things, err := client.GetThings(config_all, nil)
-		handleError(err)
+	var matchers []func(string) bool
 
-		// use tabwriter.Debug flag (last arg) to see column borders
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, OUTPUT_PADDING, ' ', 0)
-		if config_long {
-			fmt.Fprintf(w, "ID\tNAME\tALIAS\tTYPE/CLASS\tENABLED\t%s\tVALUE\tINFLUXDB\tMYSQL\t\n", fmt.Sprintf(DefaultColor, "LAST SEEN"))
+	for _, pattern := range patterns {
+		if regex {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid regular expression '%s': %v", pattern, err)
+			}
+			matchers = append(matchers, re.MatchString)
 		} else {
-			fmt.Fprintf(w, "NAME\tALIAS\tTYPE/CLASS\tENABLED\t%s\tVALUE\t\n", fmt.Sprintf(DefaultColor, "LAST SEEN"))
+			// check pattern syntax in advance, errors are ignored while matching
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("Invalid pattern '%s': %v", pattern, err)
+			}
+			glob := pattern
+			matchers = append(matchers, func(s string) bool {
+				matched, _ := filepath.Match(glob, s)
+				return matched
+			})
 		}
-		for i := 0; i < len(things); i++ {
+	}
+
+	return func(thing *api.Thing) bool {
+		for _, match := range matchers {
+			if match(thing.Name) || (thing.Alias != "" && match(thing.Alias)) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
 
-			tm := time.Unix(int64(things[i].LastSeen), 0)
-			td := time.Now().Sub(tm).Truncate(time.Second)
-			age := formatAge(td)
+// setThingsEnabled enables or disables all things matching patterns and
+// prints summary of changes
+func setThingsEnabled(ctx context.Context, client api.PiotAPI, out io.Writer, patterns []string, enabled bool) error {
 
-			if things[i].LastSeenInterval > 0 {
-				time_diff := time.Now().Unix() - int64(things[i].LastSeen)
-				if time_diff > int64(things[i].LastSeenInterval) {
-					age = fmt.Sprintf(RedColor, age)
-				} else {
-					age = fmt.Sprintf(GreenColor, age)
-				}
+	filter, err := newThingPatternFilter(patterns, config_regex)
+	if err != nil {
+		return err
+	}
+
+	err = client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	things, err := getThings(ctx, client, config_all, filter)
+	if err != nil {
+		return err
+	}
+
+	if len(things) == 0 {
+		fmt.Fprintln(out, "No matching things found")
+		return nil
+	}
+
+	// rows of already changed things are printed even if update fails
+	w := tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "NAME\tALIAS\tBEFORE\tAFTER\tSTATUS\t\n")
+	for i := 0; i < len(things); i++ {
+
+		status := "unchanged"
+		after := things[i].Enabled
+
+		if things[i].Enabled != enabled {
+			if config_dry_run {
+				status = "dry run"
 			} else {
-				age = fmt.Sprintf(DefaultColor, age)
+				_, err = client.UpdateThingContext(ctx, things[i].Id, &api.ThingAttributes{Enabled: &enabled})
+				if err != nil {
+					return err
+				}
+				status = "changed"
 			}
+			after = enabled
+		}
 
-			if config_long {
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%s\t%v\t%v\t%v\t\n",
-					things[i].Id,
-					things[i].Name,
-					things[i].Alias,
-					things[i].Type+"/"+things[i].Sensor.Class,
-					things[i].Enabled,
-					age,
-					things[i].Sensor.Value,
-					things[i].StoreInfluxDb,
-					things[i].StoreMysqlDb,
-				)
-			} else {
-				fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%v\t\n",
-					things[i].Name,
-					things[i].Alias,
-					things[i].Type+"/"+things[i].Sensor.Class,
-					things[i].Enabled,
-					age,
-					things[i].Sensor.Value,
-				)
+		fmt.Fprintf(w, "%s\t%s\t%v\t%v\t%s\t\n",
+			things[i].Name,
+			things[i].Alias,
+			things[i].Enabled,
+			after,
+			status,
+		)
+	}
+
+	return nil
+}
+
+// addThingAttributeFlags registers flags for editable thing attributes
+func addThingAttributeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&config_thing_alias, "alias", "", "thing alias")
+	cmd.Flags().BoolVar(&config_thing_enabled, "enabled", true, "thing is enabled")
+	cmd.Flags().Int32Var(&config_thing_last_seen_interval, "last-seen-interval", 0, "expected interval of thing activity in seconds (0 = not monitored)")
+	cmd.Flags().BoolVar(&config_thing_store_influxdb, "store-influxdb", false, "store thing values to InfluxDB")
+	cmd.Flags().BoolVar(&config_thing_store_mysqldb, "store-mysqldb", false, "store thing values to MySQL")
+	cmd.Flags().StringVar(&config_thing_class, "class", "", "sensor class (e.g. temperature, humidity)")
+	cmd.Flags().StringVar(&config_thing_unit, "unit", "", "sensor unit (e.g. C, %)")
+}
+
+// getThingAttributes builds thing attributes from flags explicitly set by user
+func getThingAttributes(cmd *cobra.Command) *api.ThingAttributes {
+
+	attrs := &api.ThingAttributes{}
+	flags := cmd.Flags()
+
+	if flags.Changed("alias") {
+		attrs.Alias = &config_thing_alias
+	}
+	if flags.Changed("enabled") {
+		attrs.Enabled = &config_thing_enabled
+	}
+	if flags.Changed("last-seen-interval") {
+		attrs.LastSeenInterval = &config_thing_last_seen_interval
+	}
+	if flags.Changed("store-influxdb") {
+		attrs.StoreInfluxDb = &config_thing_store_influxdb
+	}
+	if flags.Changed("store-mysqldb") {
+		attrs.StoreMysqlDb = &config_thing_store_mysqldb
+	}
+	if flags.Changed("class") {
+		attrs.SensorClass = &config_thing_class
+	}
+	if flags.Changed("unit") {
+		attrs.SensorUnit = &config_thing_unit
+	}
+
+	return attrs
+}
+
+// thingAge returns time elapsed since thing was seen last time
+func thingAge(thing *api.Thing) time.Duration {
+	tm := time.Unix(int64(thing.LastSeen), 0)
+	return time.Now().Sub(tm).Truncate(time.Second)
+}
+
+// isThingOverdue returns true if thing has last seen interval configured and
+// it wasn't seen within this interval
+func isThingOverdue(thing *api.Thing) bool {
+	if thing.LastSeenInterval <= 0 {
+		return false
+	}
+	time_diff := time.Now().Unix() - int64(thing.LastSeen)
+	return time_diff > int64(thing.LastSeenInterval)
+}
+
+// formatThingAge returns age of thing colored according to its last seen
+// interval (green - fresh, red - overdue)
+func formatThingAge(thing *api.Thing) string {
+
+	age := formatAge(thingAge(thing))
+
+	if thing.LastSeenInterval > 0 {
+		if isThingOverdue(thing) {
+			return fmt.Sprintf(RedColor, age)
+		}
+		return fmt.Sprintf(GreenColor, age)
+	}
+
+	return fmt.Sprintf(DefaultColor, age)
+}
+
+// findThings resolves list of thing references (names or ids) to things.
+// Error is returned if any of references doesn't match existing thing.
+func findThings(ctx context.Context, client api.PiotAPI, refs []string) ([]api.Thing, error) {
+
+	things, err := getThings(ctx, client, config_all, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []api.Thing
+
+	for _, ref := range refs {
+		found := false
+		for i := 0; i < len(things); i++ {
+			if things[i].Id == ref || things[i].Name == ref {
+				result = append(result, things[i])
+				found = true
+				break
 			}
 		}
-		w.Flush()
-	},
+		if !found {
+			return nil, fmt.Errorf("Thing '%s' does not exist", ref)
+		}
+	}
+
+	return result, nil
 }
 
-var thingDeleteCmd = &cobra.Command{
-	Use:   "delete",
-	Short: "Delete thing",
+// prepareThingListing returns listing columns and filter set by flags
+func prepareThingListing() ([]string, api.ThingFilterFunctionType, error) {
+
+	columns_spec := THING_COLUMNS_DEFAULT
+	if config_long {
+		columns_spec = THING_COLUMNS_LONG
+	}
+	if config_columns != "" {
+		columns_spec = config_columns
+	}
+	columns, err := parseThingColumns(columns_spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var filter api.ThingFilterFunctionType
+	if config_filter != "" {
+		filter, err = newThingExpressionFilter(config_filter)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if config_sort_by != "" {
+		if _, err := getThingField(config_sort_by); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return columns, filter, nil
+}
+
+// getSortedThings fetches things and sorts them according to flags
+func getSortedThings(ctx context.Context, client api.PiotAPI, filter api.ThingFilterFunctionType) ([]api.Thing, error) {
+
+	things, err := getThings(ctx, client, config_all, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if config_sort_by != "" {
+		err = sortThings(things, config_sort_by, config_reverse)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return things, nil
+}
+
+// addThingListFlags registers flags controlling listing of things
+func addThingListFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&config_all, "all", false, "Show all things across orgs")
+	cmd.Flags().BoolVarP(&config_long, "long", "l", false, "use long listing (show more columns)")
+	cmd.Flags().StringVarP(&config_columns, "columns", "c", "", "comma separated list of columns (e.g. name,alias,value,last_seen)")
+	cmd.Flags().StringVarP(&config_sort_by, "sort-by", "s", "", "sort things by field (e.g. name, last_seen, value)")
+	cmd.Flags().BoolVarP(&config_reverse, "reverse", "r", false, "reverse sort order")
+	cmd.Flags().StringVarP(&config_filter, "filter", "f", "", "show only things matching all conditions (e.g. type=sensor,enabled=true,value>20)")
+}
+
+// runThing prints table (or tree) of things
+func runThing(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+	columns, filter, err := prepareThingListing()
+	if err != nil {
+		return err
+	}
+
+	err = client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	things, err := getSortedThings(ctx, client, filter)
+	if err != nil {
+		return err
+	}
+
+	// use tabwriter.Debug flag (last arg) to see column borders
+	w := tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+
+	if config_tree {
+		// parent relation is not supported by all server versions,
+		// naming convention is used as fallback
+		parents, err := getThingParents(ctx, client, config_all)
+		if err != nil {
+			log.Debugf("Parent relation of things not available (%v), grouping by names", err)
+			parents = nil
+		}
+
+		printThingHeader(w, columns, nil, "HEALTH")
+		printThingTree(w, buildThingTree(things, parents), columns)
+		return w.Flush()
+	}
+
+	printThingHeader(w, columns, nil)
+	for i := 0; i < len(things); i++ {
+		printThingRow(w, &things[i], columns, nil)
+	}
+	return w.Flush()
+}
+
+var thingCmd = &cobra.Command{
+	Use:   "thing",
+	Short: "Get list of things",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
-		client := api.NewClient(log)
-
-		err := client.Login()
+		err := runThing(cmd.Context(), newApiClient(), cmd.OutOrStdout())
 		handleError(err)
+	},
+}
+
+// runThingDelete deletes things identified by names or ids, each deletion is
+// confirmed by user unless --yes flag is set
+func runThingDelete(ctx context.Context, client api.PiotAPI, out io.Writer, refs []string) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	things, err := findThings(ctx, client, refs)
+	if err != nil {
+		return err
+	}
+
+	if config_dry_run {
+		fmt.Fprintln(out, "Things to be deleted (dry run):")
+		for i := 0; i < len(things); i++ {
+			fmt.Fprintf(out, "  %s (%s)\n", things[i].Name, things[i].Id)
+		}
+		return nil
+	}
+
+	for i := 0; i < len(things); i++ {
+		if !config_yes && !askForConfirmation(out, fmt.Sprintf("Delete thing '%s' (%s)?", things[i].Name, things[i].Id)) {
+			fmt.Fprintf(out, "Skipping thing '%s'\n", things[i].Name)
+			continue
+		}
+
+		err = client.DeleteThingContext(ctx, things[i].Id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Thing '%s' (%s) deleted\n", things[i].Name, things[i].Id)
+	}
+
+	return nil
+}
 
-		err = client.DeleteThing()
+var thingDeleteCmd = &cobra.Command{
+	Use:   "delete NAME|ID...",
+	Short: "Delete things",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runThingDelete(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args)
 		handleError(err)
 	},
 }
 
+// runThingCreate creates thing in active organization
+func runThingCreate(ctx context.Context, client api.PiotAPI, out io.Writer, name string, attrs *api.ThingAttributes) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// server creates things in active org of user profile
+	if viper.GetString("org") != "" {
+		org, err := getSelectedOrg(ctx, client)
+		if err != nil {
+			return err
+		}
+		profile, err := client.GetUserProfileContext(ctx)
+		if err != nil {
+			return err
+		}
+		if profile.OrgId != org.Id {
+			return fmt.Errorf("Things could be created only in active organization, use 'org set %s' first", org.Name)
+		}
+	}
+
+	thing, err := client.CreateThingContext(ctx, name, config_thing_type, attrs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Thing '%s' created (%s)\n", thing.Name, thing.Id)
+
+	return nil
+}
+
 var thingCreateCmd = &cobra.Command{
-	Use:   "create",
+	Use:   "create NAME",
 	Short: "Create new thing",
 	Long:  ``,
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		client := api.NewClient(log)
+		err := runThingCreate(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args[0], getThingAttributes(cmd))
+		handleError(err)
+	},
+}
+
+// runThingUpdate changes attributes of thing and prints changed fields
+func runThingUpdate(ctx context.Context, client api.PiotAPI, out io.Writer, ref string, attrs *api.ThingAttributes) error {
 
-		err := client.Login()
+	if attrs.IsEmpty() {
+		return fmt.Errorf("Nothing to update, try to run command with -h flag to see supported attributes")
+	}
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	things, err := findThings(ctx, client, []string{ref})
+	if err != nil {
+		return err
+	}
+	before := things[0]
+
+	after, err := client.UpdateThingContext(ctx, before.Id, attrs)
+	if err != nil {
+		return err
+	}
+
+	return printThingDiff(out, &before, after)
+}
+
+var thingUpdateCmd = &cobra.Command{
+	Use:   "update NAME|ID",
+	Short: "Update attributes of existing thing",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runThingUpdate(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args[0], getThingAttributes(cmd))
 		handleError(err)
+	},
+}
 
-		id, err := client.CreateThing(args[0], config_thing_type)
+// runThingShow prints details of thing and optionally its last readings from
+// InfluxDB
+func runThingShow(ctx context.Context, client api.PiotAPI, out io.Writer, ref string) error {
+
+	err := client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	things, err := findThings(ctx, client, []string{ref})
+	if err != nil {
+		return err
+	}
+	thing := things[0]
+
+	last_seen := "never"
+	if thing.LastSeen > 0 {
+		last_seen = fmt.Sprintf("%s (%s ago)",
+			time.Unix(int64(thing.LastSeen), 0).Format(time.RFC3339),
+			formatAge(thingAge(&thing)))
+	}
+
+	overdue := "n/a (not monitored)"
+	if thing.LastSeenInterval > 0 {
+		if isThingOverdue(&thing) {
+			overdue = fmt.Sprintf(RedColor, "yes")
+		} else {
+			overdue = fmt.Sprintf(GreenColor, "no")
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	fmt.Fprintf(w, "Id:\t%s\n", thing.Id)
+	fmt.Fprintf(w, "Name:\t%s\n", thing.Name)
+	fmt.Fprintf(w, "Alias:\t%s\n", thing.Alias)
+	fmt.Fprintf(w, "Type:\t%s\n", thing.Type)
+	fmt.Fprintf(w, "Class:\t%s\n", thing.Sensor.Class)
+	fmt.Fprintf(w, "Unit:\t%s\n", thing.Sensor.Unit)
+	fmt.Fprintf(w, "Value:\t%s\n", thing.Sensor.Value)
+	fmt.Fprintf(w, "Enabled:\t%v\n", thing.Enabled)
+	fmt.Fprintf(w, "Store InfluxDB:\t%v\n", thing.StoreInfluxDb)
+	fmt.Fprintf(w, "Store MySQL:\t%v\n", thing.StoreMysqlDb)
+	fmt.Fprintf(w, "Last seen:\t%s\n", last_seen)
+	fmt.Fprintf(w, "Last seen interval:\t%ds\n", thing.LastSeenInterval)
+	fmt.Fprintf(w, "Overdue:\t%s\n", overdue)
+	w.Flush()
+
+	if config_history <= 0 {
+		return nil
+	}
+
+	// history is read from database of org (same as export of sensors)
+	org, err := getOrg(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	ic, err := newInfluxClient()
+	if err != nil {
+		return err
+	}
+	defer ic.Close()
+
+	values, err := fetchLastSensorValues(ctx, ic, org.InfluxDb, thing.Id, config_history)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	fmt.Fprintf(w, "DATE\tVALUE\t\n")
+	for _, value := range values {
+		if value.Value == SENSOR_VALUE_EMPTY {
+			fmt.Fprintf(w, "%s\t%s\t\n", value.Date.Local().Format(time.RFC3339), "nil")
+		} else {
+			fmt.Fprintf(w, "%s\t%.2f\t\n", value.Date.Local().Format(time.RFC3339), value.Value)
+		}
+	}
+	return w.Flush()
+}
+
+var thingShowCmd = &cobra.Command{
+	Use:   "show NAME|ID",
+	Short: "Show details of thing",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runThingShow(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args[0])
+		handleError(err)
+	},
+}
+
+var thingEnableCmd = &cobra.Command{
+	Use:   "enable PATTERN...",
+	Short: "Enable things matching name or alias patterns",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := setThingsEnabled(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args, true)
 		handleError(err)
+	},
+}
 
-		log.Infof("%s", id)
+var thingDisableCmd = &cobra.Command{
+	Use:   "disable PATTERN...",
+	Short: "Disable things matching name or alias patterns",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := setThingsEnabled(cmd.Context(), newApiClient(), cmd.OutOrStdout(), args, false)
+		handleError(err)
 	},
 }
 
+// printThingDiff prints table of editable thing fields that differ
+func printThingDiff(out io.Writer, before, after *api.Thing) error {
+
+	type field struct {
+		name   string
+		before interface{}
+		after  interface{}
+	}
+
+	fields := []field{
+		{"alias", before.Alias, after.Alias},
+		{"enabled", before.Enabled, after.Enabled},
+		{"last_seen_interval", before.LastSeenInterval, after.LastSeenInterval},
+		{"store_influxdb", before.StoreInfluxDb, after.StoreInfluxDb},
+		{"store_mysqldb", before.StoreMysqlDb, after.StoreMysqlDb},
+		{"sensor_class", before.Sensor.Class, after.Sensor.Class},
+		{"sensor_unit", before.Sensor.Unit, after.Sensor.Unit},
+	}
+
+	var changed []field
+	for _, f := range fields {
+		if f.before != f.after {
+			changed = append(changed, f)
+		}
+	}
+
+	if len(changed) == 0 {
+		fmt.Fprintf(out, "No changes for thing '%s'\n", after.Name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, OUTPUT_PADDING, ' ', 0)
+	fmt.Fprintf(w, "FIELD\tBEFORE\tAFTER\t\n")
+	for _, f := range changed {
+		fmt.Fprintf(w, "%s\t%v\t%v\t\n", f.name, f.before, f.after)
+	}
+	return w.Flush()
+}
+
 func init() {
 	rootCmd.AddCommand(thingCmd)
-	thingCmd.Flags().BoolVar(&config_all, "all", false, "Show all things across orgs")
-	thingCmd.Flags().BoolVarP(&config_long, "long", "l", false, "use long listing (show more columns)")
+	addThingListFlags(thingCmd)
+	thingCmd.Flags().BoolVarP(&config_tree, "tree", "t", false, "group sensors under their parent devices")
 
 	thingCmd.AddCommand(thingDeleteCmd)
+	thingDeleteCmd.Flags().BoolVarP(&config_yes, "yes", "y", false, "do not ask for confirmation")
+	thingDeleteCmd.Flags().BoolVar(&config_dry_run, "dry-run", false, "only list things that would be deleted")
 
 	thingCmd.AddCommand(thingCreateCmd)
 	thingCreateCmd.Flags().StringVar(&config_thing_type, "type", "device", "Thing type (device, sensor, switch)")
+	addThingAttributeFlags(thingCreateCmd)
+
+	thingCmd.AddCommand(thingShowCmd)
+	thingShowCmd.Flags().IntVar(&config_history, "history", 0, "show last N readings from InfluxDB")
+
+	thingCmd.AddCommand(thingUpdateCmd)
+	addThingAttributeFlags(thingUpdateCmd)
+
+	for _, c := range []*cobra.Command{thingEnableCmd, thingDisableCmd} {
+		thingCmd.AddCommand(c)
+		c.Flags().BoolVar(&config_regex, "regex", false, "interpret patterns as regular expressions instead of globs")
+		c.Flags().BoolVar(&config_dry_run, "dry-run", false, "only list things that would be changed")
+		c.Flags().BoolVar(&config_all, "all", false, "match things across all orgs")
+	}
 }
@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"piot-cli/api"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// thingField describes one field of thing which can be used as listing column,
+// sort key or filter condition
+type thingField struct {
+	header string
+	// raw value used for sorting and filtering
+	value func(t *api.Thing) interface{}
+	// formatted value used for listing, fmt %v of raw value is used if nil
+	format func(t *api.Thing) string
+}
+
+var thingFields = map[string]thingField{
+	"id":    {header: "ID", value: func(t *api.Thing) interface{} { return t.Id }},
+	"name":  {header: "NAME", value: func(t *api.Thing) interface{} { return t.Name }},
+	"alias": {header: "ALIAS", value: func(t *api.Thing) interface{} { return t.Alias }},
+	"type":  {header: "TYPE", value: func(t *api.Thing) interface{} { return t.Type }},
+	"class": {header: "CLASS", value: func(t *api.Thing) interface{} { return t.Sensor.Class }},
+	"unit":  {header: "UNIT", value: func(t *api.Thing) interface{} { return t.Sensor.Unit }},
+	"type_class": {
+		header: "TYPE/CLASS",
+		value:  func(t *api.Thing) interface{} { return t.Type + "/" + t.Sensor.Class },
+	},
+	"value": {
+		header: "VALUE",
+		value: func(t *api.Thing) interface{} {
+			// numeric values are compared as numbers
+			if v, err := strconv.ParseFloat(t.Sensor.Value, 64); err == nil {
+				return v
+			}
+			return t.Sensor.Value
+		},
+		format: func(t *api.Thing) string { return t.Sensor.Value },
+	},
+	"enabled": {header: "ENABLED", value: func(t *api.Thing) interface{} { return t.Enabled }},
+	"last_seen": {
+		header: fmt.Sprintf(DefaultColor, "LAST SEEN"),
+		value:  func(t *api.Thing) interface{} { return int64(t.LastSeen) },
+		format: formatThingAge,
+	},
+	"last_seen_interval": {header: "INTERVAL", value: func(t *api.Thing) interface{} { return int64(t.LastSeenInterval) }},
+	"store_influxdb":     {header: "INFLUXDB", value: func(t *api.Thing) interface{} { return t.StoreInfluxDb }},
+	"store_mysqldb":      {header: "MYSQL", value: func(t *api.Thing) interface{} { return t.StoreMysqlDb }},
+	"overdue":            {header: "OVERDUE", value: func(t *api.Thing) interface{} { return isThingOverdue(t) }},
+	"org_id":             {header: "ORG ID", value: func(t *api.Thing) interface{} { return t.OrgId }},
+}
+
+const (
+	THING_COLUMNS_DEFAULT = "name,alias,type_class,enabled,last_seen,value"
+	THING_COLUMNS_LONG    = "id,name,alias,type_class,enabled,last_seen,value,store_influxdb,store_mysqldb"
+)
+
+func thingFieldNames() string {
+	names := make([]string, 0, len(thingFields))
+	for name := range thingFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func getThingField(name string) (*thingField, error) {
+	field, ok := thingFields[strings.TrimSpace(name)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown thing field '%s', supported fields: %s", name, thingFieldNames())
+	}
+	return &field, nil
+}
+
+// parseThingColumns validates comma separated list of column names
+func parseThingColumns(spec string) ([]string, error) {
+
+	var columns []string
+
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if _, err := getThingField(name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+
+	return columns, nil
+}
+
+// splitFilterExpression splits condition to field, operator and value,
+// supported operators are =, !=, <, <=, > and >=
+func splitFilterExpression(expr string) (string, string, string, bool) {
+
+	i := strings.IndexAny(expr, "!=<>")
+	if i < 0 {
+		return "", "", "", false
+	}
+
+	op := expr[i : i+1]
+	if op != "=" && strings.HasPrefix(expr[i+1:], "=") {
+		op += "="
+	}
+	if op == "!" {
+		return "", "", "", false
+	}
+
+	return expr[:i], op, expr[i+len(op):], true
+}
+
+// thingNumber returns raw value of thing field as number, ok is false for non
+// numeric values (e.g. sensor value which is not a number)
+func thingNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// newThingExpressionFilter creates filter function from comma separated list
+// of conditions in form field=value, field!=value or numeric comparison
+// field<value, field<=value, field>value, field>=value (e.g.
+// type=sensor,enabled=true,value>20). Values could contain shell glob
+// patterns, numeric values are compared as numbers (value=21 matches 21.0),
+// things with non numeric value never match numeric comparison. Thing matches
+// the filter if all conditions are met.
+func newThingExpressionFilter(spec string) (api.ThingFilterFunctionType, error) {
+
+	type condition struct {
+		field   *thingField
+		op      string
+		pattern string
+		number  float64
+		numeric bool
+	}
+
+	var conditions []condition
+
+	for _, expr := range strings.Split(spec, ",") {
+
+		name, op, pattern, ok := splitFilterExpression(expr)
+		if !ok {
+			return nil, fmt.Errorf("Invalid filter expression '%s', expected field=value, field!=value or field<value (also <=, >, >=)", expr)
+		}
+
+		field, err := getThingField(name)
+		if err != nil {
+			return nil, err
+		}
+
+		pattern = strings.TrimSpace(pattern)
+		number, err := strconv.ParseFloat(pattern, 64)
+		numeric := err == nil
+
+		if op != "=" && op != "!=" && !numeric {
+			return nil, fmt.Errorf("Invalid filter expression '%s', value compared by %s must be number", expr, op)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid filter pattern '%s': %v", pattern, err)
+		}
+
+		conditions = append(conditions, condition{field, op, pattern, number, numeric})
+	}
+
+	matches := func(c condition, thing *api.Thing) bool {
+		raw := c.field.value(thing)
+		value, isNumber := thingNumber(raw)
+
+		switch c.op {
+		case "<":
+			return isNumber && value < c.number
+		case "<=":
+			return isNumber && value <= c.number
+		case ">":
+			return isNumber && value > c.number
+		case ">=":
+			return isNumber && value >= c.number
+		}
+
+		var equal bool
+		if c.numeric && isNumber {
+			equal = value == c.number
+		} else {
+			equal, _ = filepath.Match(c.pattern, fmt.Sprintf("%v", raw))
+		}
+
+		return equal == (c.op == "=")
+	}
+
+	return func(thing *api.Thing) bool {
+		for _, c := range conditions {
+			if !matches(c, thing) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// compareThingValues returns true if a is less than b, values of different
+// types (e.g. numeric and non numeric sensor values) are ordered by type
+func compareThingValues(a, b interface{}) bool {
+
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return av < bv
+		}
+		return false
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return av < bv
+		}
+		return true
+	case int64:
+		if bv, ok := b.(int64); ok {
+			return av < bv
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return !av && bv
+		}
+	}
+
+	return false
+}
+
+// thingValueRank groups values for sorting, numbers (and other typed values)
+// go first, then non numeric strings and empty values are always last
+func thingValueRank(value interface{}) int {
+	if s, ok := value.(string); ok {
+		if s == "" {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// sortThings sorts things by field, original order is kept for equal values.
+// Reverse order applies within groups of values (see thingValueRank), so
+// empty values stay last in both directions.
+func sortThings(things []api.Thing, fieldName string, reverse bool) error {
+
+	field, err := getThingField(fieldName)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(things, func(i, j int) bool {
+		a, b := field.value(&things[i]), field.value(&things[j])
+		if rank_a, rank_b := thingValueRank(a), thingValueRank(b); rank_a != rank_b {
+			return rank_a < rank_b
+		}
+		if reverse {
+			return compareThingValues(b, a)
+		}
+		return compareThingValues(a, b)
+	})
+
+	return nil
+}
+
+func formatThingField(thing *api.Thing, name string) string {
+	field := thingFields[name]
+	if field.format != nil {
+		return field.format(thing)
+	}
+	return fmt.Sprintf("%v", field.value(thing))
+}
+
+// printThingHeader writes headers of selected columns, headers could be
+// replaced by values from overrides
+func printThingHeader(w io.Writer, columns []string, overrides map[string]string, extra ...string) {
+	for _, column := range columns {
+		if header, ok := overrides[column]; ok {
+			fmt.Fprintf(w, "%s\t", header)
+		} else {
+			fmt.Fprintf(w, "%s\t", thingFields[column].header)
+		}
+	}
+	for _, header := range extra {
+		fmt.Fprintf(w, "%s\t", header)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// printThingRow writes selected columns of thing, formatted values could be
+// replaced by values from overrides (e.g. indented name in tree listing)
+func printThingRow(w io.Writer, thing *api.Thing, columns []string, overrides map[string]string, extra ...string) {
+	for _, column := range columns {
+		if value, ok := overrides[column]; ok {
+			fmt.Fprintf(w, "%s\t", value)
+		} else {
+			fmt.Fprintf(w, "%s\t", formatThingField(thing, column))
+		}
+	}
+	for _, value := range extra {
+		fmt.Fprintf(w, "%s\t", value)
+	}
+	fmt.Fprintf(w, "\n")
+}
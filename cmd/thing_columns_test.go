@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"piot-cli/api"
+	"reflect"
+	"testing"
+)
+
+func thingNames(things []api.Thing) []string {
+	var names []string
+	for _, thing := range things {
+		names = append(names, thing.Name)
+	}
+	return names
+}
+
+func TestSortThingsByValue(t *testing.T) {
+
+	things := func() []api.Thing {
+		return []api.Thing{
+			{Name: "empty", Sensor: api.SensorData{Value: ""}},
+			{Name: "ten", Sensor: api.SensorData{Value: "10"}},
+			{Name: "text", Sensor: api.SensorData{Value: "on"}},
+			{Name: "minus", Sensor: api.SensorData{Value: "-1.5"}},
+			{Name: "two", Sensor: api.SensorData{Value: "2"}},
+		}
+	}
+
+	cases := []struct {
+		reverse  bool
+		expected []string
+	}{
+		{false, []string{"minus", "two", "ten", "text", "empty"}},
+		{true, []string{"ten", "two", "minus", "text", "empty"}},
+	}
+
+	for _, c := range cases {
+		result := things()
+		if err := sortThings(result, "value", c.reverse); err != nil {
+			t.Fatal(err)
+		}
+		if names := thingNames(result); !reflect.DeepEqual(names, c.expected) {
+			t.Errorf("reverse=%t: expected %v, got %v", c.reverse, c.expected, names)
+		}
+	}
+}
+
+func TestSortThingsEmptyAliasLast(t *testing.T) {
+
+	things := []api.Thing{
+		{Name: "a", Alias: ""},
+		{Name: "b", Alias: "Kitchen"},
+		{Name: "c", Alias: "Garage"},
+	}
+
+	err := sortThings(things, "alias", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"b", "c", "a"}
+	if names := thingNames(things); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestSortThingsUnknownField(t *testing.T) {
+	if err := sortThings(nil, "unknown", false); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
+
+func TestThingExpressionFilter(t *testing.T) {
+
+	things := []api.Thing{
+		{Name: "integer", Type: "sensor", Sensor: api.SensorData{Value: "21"}},
+		{Name: "decimal", Type: "sensor", Sensor: api.SensorData{Value: "21.0"}},
+		{Name: "warm", Type: "sensor", Sensor: api.SensorData{Value: "25.5"}},
+		{Name: "text", Type: "sensor", Sensor: api.SensorData{Value: "on"}},
+		{Name: "empty", Type: "device", Sensor: api.SensorData{Value: ""}, LastSeenInterval: 60},
+	}
+
+	cases := []struct {
+		spec     string
+		expected []string
+	}{
+		{"value=21", []string{"integer", "decimal"}},
+		{"value=21.0", []string{"integer", "decimal"}},
+		{"value!=21", []string{"warm", "text", "empty"}},
+		{"value=2*", []string{"integer", "decimal", "warm"}},
+		{"value>21", []string{"warm"}},
+		{"value>=21", []string{"integer", "decimal", "warm"}},
+		{"value<25.5", []string{"integer", "decimal"}},
+		{"value<=-1", nil},
+		{"value=on", []string{"text"}},
+		{"value=", []string{"empty"}},
+		{"type=sensor,value>21", []string{"warm"}},
+		{"last_seen_interval>0", []string{"empty"}},
+	}
+
+	for _, c := range cases {
+		filter, err := newThingExpressionFilter(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", c.spec, err)
+		}
+
+		var names []string
+		for i := range things {
+			if filter(&things[i]) {
+				names = append(names, things[i].Name)
+			}
+		}
+
+		if !reflect.DeepEqual(names, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.spec, c.expected, names)
+		}
+	}
+}
+
+func TestThingExpressionFilterInvalid(t *testing.T) {
+	for _, spec := range []string{"value", "value!21", "value>on", "value<", "unknown=1", "name=[a"} {
+		if _, err := newThingExpressionFilter(spec); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"piot-cli/api"
+	"piot-cli/apitest"
+
+	"github.com/spf13/viper"
+)
+
+// seedThings fills fake server by device with sensors in active organization
+// and sensor of other organization
+func seedThings(server *apitest.Server) {
+
+	home := server.AddOrg(api.Org{Name: "HOME", InfluxDb: "home"})
+	device := server.AddThing(api.Thing{Name: "B3007", Type: "device", Enabled: true, OrgId: home.Id})
+	temperature := server.AddThing(api.Thing{
+		Name:    "B3007-Temp",
+		Alias:   "Kitchen",
+		Type:    "sensor",
+		Enabled: true,
+		Sensor:  api.SensorData{Value: "21.5", Class: "temperature", Unit: "C"},
+		OrgId:   home.Id,
+	})
+	humidity := server.AddThing(api.Thing{
+		Name:   "B3007-Hum",
+		Type:   "sensor",
+		Sensor: api.SensorData{Value: "45", Class: "humidity", Unit: "%"},
+		OrgId:  home.Id,
+	})
+	server.SetThingParent(temperature.Id, device.Id)
+	server.SetThingParent(humidity.Id, device.Id)
+
+	cottage := server.AddOrg(api.Org{Name: "COTTAGE", InfluxDb: "cottage"})
+	server.AddThing(api.Thing{
+		Name:    "C1-Temp",
+		Type:    "sensor",
+		Enabled: true,
+		Sensor:  api.SensorData{Value: "-3", Class: "temperature", Unit: "C"},
+		OrgId:   cottage.Id,
+	})
+}
+
+// listThingsAfter runs command and prints listing of all things then
+func listThingsAfter(run func(ctx context.Context, client api.PiotAPI, out io.Writer) error) func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+	return func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+		if err := run(ctx, client, out); err != nil {
+			return err
+		}
+		config_all = true
+		config_dry_run = false
+		config_columns = "name,alias,type_class,enabled,value"
+		return runThing(ctx, client, out)
+	}
+}
+
+func TestThingCommands(t *testing.T) {
+
+	columns := func(*apitest.Server) { config_columns = "name,alias,type_class,enabled,value" }
+
+	runCommandCases(t, seedThings, []commandCase{
+		{
+			name:  "thing",
+			setup: columns,
+			run:   runThing,
+		},
+		{
+			name: "thing-all-sorted",
+			setup: func(server *apitest.Server) {
+				columns(server)
+				config_all = true
+				config_sort_by = "value"
+				config_reverse = true
+			},
+			run: runThing,
+		},
+		{
+			name: "thing-filter",
+			setup: func(server *apitest.Server) {
+				columns(server)
+				config_filter = "type=sensor,enabled=true"
+			},
+			run: runThing,
+		},
+		{
+			name: "thing-org",
+			setup: func(server *apitest.Server) {
+				columns(server)
+				viper.Set("org", "COTTAGE")
+			},
+			run: runThing,
+		},
+		{
+			// things of other organization are selected by server, user
+			// does not need to be admin
+			name: "thing-org-not-admin",
+			setup: func(server *apitest.Server) {
+				config_columns = "name,type_class,org_id"
+				server.SetAdmin(false)
+				viper.Set("org", "COTTAGE")
+			},
+			run: runThing,
+		},
+		{
+			name: "thing-org-tree-not-admin",
+			setup: func(server *apitest.Server) {
+				config_columns = "name,alias,value"
+				config_tree = true
+				server.SetAdmin(false)
+				server.SetActiveOrg(server.Orgs()[1].Id)
+				viper.Set("org", "HOME")
+			},
+			run: runThing,
+		},
+		{
+			name: "thing-org-not-member",
+			setup: func(server *apitest.Server) {
+				server.SetAdmin(false)
+				server.RemoveOrgMember(server.Orgs()[1].Id, server.Users()[0].Id)
+				viper.Set("org", "COTTAGE")
+			},
+			run: runThing,
+			err: "Organization 'COTTAGE' does not exist",
+		},
+		{
+			name: "thing-tree",
+			setup: func(server *apitest.Server) {
+				config_columns = "name,alias,value"
+				config_tree = true
+			},
+			run: runThing,
+		},
+		{
+			name:  "thing-unknown-column",
+			setup: func(*apitest.Server) { config_columns = "name,unknown" },
+			run:   runThing,
+			err:   "unknown",
+		},
+		{
+			name: "thing-show",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runThingShow(ctx, client, out, "B3007-Temp")
+			},
+		},
+		{
+			name: "thing-show-missing",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runThingShow(ctx, client, out, "missing")
+			},
+			err: "Thing 'missing' does not exist",
+		},
+		{
+			name: "thing-create",
+			run: listThingsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				config_thing_type = "sensor"
+				alias := "Garage"
+				return runThingCreate(ctx, client, out, "B3008-Temp", &api.ThingAttributes{Alias: &alias})
+			}),
+		},
+		{
+			name: "thing-create-update-failure",
+			setup: func(server *apitest.Server) {
+				config_thing_type = "sensor"
+				server.Fail("updateThing", "FORBIDDEN", "Not allowed")
+			},
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				alias := "Garage"
+				return runThingCreate(ctx, client, out, "B3008-Temp", &api.ThingAttributes{Alias: &alias})
+			},
+			err: "Thing 'B3008-Temp' created (",
+		},
+		{
+			name: "thing-create-not-active-org",
+			setup: func(*apitest.Server) {
+				viper.Set("org", "COTTAGE")
+			},
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runThingCreate(ctx, client, out, "C2-Temp", &api.ThingAttributes{})
+			},
+			err: "use 'org set COTTAGE' first",
+		},
+		{
+			name: "thing-update",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				alias := "Living room"
+				enabled := false
+				unit := "F"
+				return runThingUpdate(ctx, client, out, "B3007-Temp", &api.ThingAttributes{
+					Alias:      &alias,
+					Enabled:    &enabled,
+					SensorUnit: &unit,
+				})
+			},
+		},
+		{
+			name: "thing-update-unchanged",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				alias := "Kitchen"
+				return runThingUpdate(ctx, client, out, "B3007-Temp", &api.ThingAttributes{Alias: &alias})
+			},
+		},
+		{
+			name: "thing-update-nothing",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runThingUpdate(ctx, client, out, "B3007-Temp", &api.ThingAttributes{})
+			},
+			err: "Nothing to update",
+		},
+		{
+			name:  "thing-delete-dry-run",
+			setup: func(*apitest.Server) { config_dry_run = true },
+			run: listThingsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runThingDelete(ctx, client, out, []string{"B3007-Temp", "B3007-Hum"})
+			}),
+		},
+		{
+			name:  "thing-delete",
+			setup: func(*apitest.Server) { config_yes = true },
+			run: listThingsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runThingDelete(ctx, client, out, []string{"B3007-Temp", "B3007-Hum"})
+			}),
+		},
+		{
+			name:  "thing-delete-missing",
+			setup: func(*apitest.Server) { config_yes = true },
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return runThingDelete(ctx, client, out, []string{"B3007-Temp", "missing"})
+			},
+			err: "Thing 'missing' does not exist",
+		},
+		{
+			name: "thing-enable",
+			run: listThingsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return setThingsEnabled(ctx, client, out, []string{"B3007-*"}, true)
+			}),
+		},
+		{
+			name:  "thing-disable-dry-run",
+			setup: func(*apitest.Server) { config_dry_run = true },
+			run: listThingsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return setThingsEnabled(ctx, client, out, []string{"Kitchen", "B3007"}, false)
+			}),
+		},
+		{
+			name: "thing-disable-regex",
+			setup: func(*apitest.Server) {
+				config_regex = true
+				config_all = true
+			},
+			run: listThingsAfter(func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return setThingsEnabled(ctx, client, out, []string{"-Temp$"}, false)
+			}),
+		},
+		{
+			name: "thing-enable-no-match",
+			run: func(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+				return setThingsEnabled(ctx, client, out, []string{"X*"}, true)
+			},
+		},
+	})
+}
+
+func TestRunThingShowHistory(t *testing.T) {
+
+	server, client := newTestServer(t)
+	influx := newTestInfluxServer(t)
+	seedThings(server)
+
+	var id string
+	for _, thing := range server.Things() {
+		if thing.Name == "B3007-Temp" {
+			id = thing.Id
+		}
+	}
+
+	// readings are days apart, last readings are shown regardless of their age
+	day := func(d int) time.Time {
+		return time.Date(2021, 3, d, 12, 0, 0, 0, time.UTC)
+	}
+	influx.AddSensorValues("home", id,
+		apitest.InfluxPoint{Time: day(1), Value: apitest.Value(19.25)},
+		apitest.InfluxPoint{Time: day(5), Value: apitest.Value(20.5)},
+		apitest.InfluxPoint{Time: day(10), Value: apitest.Value(21.75)},
+	)
+
+	config_history = 2
+
+	var out bytes.Buffer
+	err := runThingShow(context.Background(), client, &out, "B3007-Temp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := out.String()
+	if strings.Contains(output, "19.25") {
+		t.Errorf("unexpected older reading in:\n%s", output)
+	}
+	older, newer := strings.Index(output, "20.50"), strings.Index(output, "21.75")
+	if older < 0 || newer < 0 || older > newer {
+		t.Errorf("expected last 2 readings in chronological order in:\n%s", output)
+	}
+
+	queries := influx.Queries()
+	if len(queries) != 1 || !strings.Contains(queries[0].Command, "ORDER BY time DESC LIMIT $limit") || queries[0].Parameters["limit"] != float64(2) {
+		t.Errorf("unexpected queries %+v", queries)
+	}
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"piot-cli/api"
+	"strings"
+)
+
+// last seen states of thing ordered from best to worst
+const (
+	THING_STATE_UNMONITORED = iota
+	THING_STATE_FRESH
+	THING_STATE_OVERDUE
+)
+
+type thingNode struct {
+	thing    *api.Thing
+	children []*thingNode
+}
+
+func getThingState(thing *api.Thing) int {
+	if thing.LastSeenInterval <= 0 {
+		return THING_STATE_UNMONITORED
+	}
+	if isThingOverdue(thing) {
+		return THING_STATE_OVERDUE
+	}
+	return THING_STATE_FRESH
+}
+
+// findParentByName returns device which name is the longest prefix of thing
+// name followed by dash (e.g. device B3007 for sensor B3007-Temp)
+func findParentByName(thing *api.Thing, things []api.Thing) *api.Thing {
+
+	var parent *api.Thing
+
+	for i := 0; i < len(things); i++ {
+		if things[i].Type != "device" || things[i].Id == thing.Id {
+			continue
+		}
+		if strings.HasPrefix(thing.Name, things[i].Name+"-") {
+			if parent == nil || len(things[i].Name) > len(parent.Name) {
+				parent = &things[i]
+			}
+		}
+	}
+
+	return parent
+}
+
+// buildThingTree groups things under their parent devices. Parent relation is
+// taken from parents map (thing id -> parent id) if not empty, otherwise
+// it is derived from naming convention. Things which are their own ancestors
+// (parents form cycle) would not be reachable from any root, they become
+// roots.
+func buildThingTree(things []api.Thing, parents map[string]string) []*thingNode {
+
+	nodes := map[string]*thingNode{}
+	for i := 0; i < len(things); i++ {
+		nodes[things[i].Id] = &thingNode{thing: &things[i]}
+	}
+
+	parentOf := map[*thingNode]*thingNode{}
+	for i := 0; i < len(things); i++ {
+		node := nodes[things[i].Id]
+
+		var parent *thingNode
+		if len(parents) > 0 {
+			parent = nodes[parents[things[i].Id]]
+		} else if p := findParentByName(&things[i], things); p != nil {
+			parent = nodes[p.Id]
+		}
+
+		if parent != nil && parent != node {
+			parentOf[node] = parent
+		}
+	}
+
+	// chain of ancestors is limited by number of things, it could end in
+	// cycle which doesn't contain the thing itself
+	inCycle := map[*thingNode]bool{}
+	var cycle []string
+	for i := 0; i < len(things); i++ {
+		node := nodes[things[i].Id]
+		ancestor := parentOf[node]
+		for steps := 0; ancestor != nil && ancestor != node && steps < len(things); steps++ {
+			ancestor = parentOf[ancestor]
+		}
+		if ancestor == node {
+			inCycle[node] = true
+			cycle = append(cycle, things[i].Name)
+		}
+	}
+
+	if len(cycle) > 0 {
+		log.Warningf("Parents of things form cycle, things are shown as roots: %s", strings.Join(cycle, ", "))
+	}
+
+	var roots []*thingNode
+
+	for i := 0; i < len(things); i++ {
+		node := nodes[things[i].Id]
+
+		if parent := parentOf[node]; parent != nil && !inCycle[node] {
+			parent.children = append(parent.children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	return roots
+}
+
+// worstState returns worst last seen state of the node and all its children
+// together with number of overdue things and number of monitored things
+func (n *thingNode) worstState() (int, int, int) {
+
+	state := getThingState(n.thing)
+	overdue := 0
+	monitored := 0
+	if state == THING_STATE_OVERDUE {
+		overdue++
+	}
+	if state != THING_STATE_UNMONITORED {
+		monitored++
+	}
+
+	for _, child := range n.children {
+		child_state, child_overdue, child_monitored := child.worstState()
+		if child_state > state {
+			state = child_state
+		}
+		overdue += child_overdue
+		monitored += child_monitored
+	}
+
+	return state, overdue, monitored
+}
+
+func (n *thingNode) health() string {
+
+	if len(n.children) == 0 {
+		return ""
+	}
+
+	state, overdue, monitored := n.worstState()
+
+	switch state {
+	case THING_STATE_OVERDUE:
+		return fmt.Sprintf(RedColor, fmt.Sprintf("OVERDUE %d/%d", overdue, monitored))
+	case THING_STATE_FRESH:
+		return fmt.Sprintf(GreenColor, "OK")
+	}
+
+	return fmt.Sprintf(DefaultColor, "-")
+}
+
+// printThingTree writes tree of things as tabwriter rows (one row per thing)
+func printThingTree(w io.Writer, roots []*thingNode, columns []string) {
+	for _, node := range roots {
+		printThingRow(w, node.thing, columns, nil, node.health())
+		printThingTreeChildren(w, node.children, columns, "")
+	}
+}
+
+func printThingTreeChildren(w io.Writer, nodes []*thingNode, columns []string, indent string) {
+	for i, node := range nodes {
+		branch, child_indent := "├─ ", indent+"│  "
+		if i == len(nodes)-1 {
+			branch, child_indent = "└─ ", indent+"   "
+		}
+		printThingRow(w, node.thing, columns, map[string]string{"name": indent + branch + node.thing.Name}, node.health())
+		printThingTreeChildren(w, node.children, columns, child_indent)
+	}
+}
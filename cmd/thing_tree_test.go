@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"piot-cli/api"
+	"reflect"
+	"testing"
+)
+
+// treeNames returns names of things in order of tree printing, children are
+// indented by dash
+func treeNames(nodes []*thingNode, indent string) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, indent+node.thing.Name)
+		names = append(names, treeNames(node.children, indent+"-")...)
+	}
+	return names
+}
+
+func TestBuildThingTree(t *testing.T) {
+
+	things := []api.Thing{
+		{Id: "1", Name: "B3007", Type: "device"},
+		{Id: "2", Name: "B3007-Temp", Type: "sensor"},
+		{Id: "3", Name: "B3007-Hum", Type: "sensor"},
+		{Id: "4", Name: "Garage", Type: "sensor"},
+	}
+
+	cases := []struct {
+		name     string
+		parents  map[string]string
+		expected []string
+	}{
+		{"naming convention", nil, []string{"B3007", "-B3007-Temp", "-B3007-Hum", "Garage"}},
+		{"parents", map[string]string{"4": "1"}, []string{"B3007", "-Garage", "B3007-Temp", "B3007-Hum"}},
+		{"self parent", map[string]string{"1": "1"}, []string{"B3007", "B3007-Temp", "B3007-Hum", "Garage"}},
+	}
+
+	for _, c := range cases {
+		items := append([]api.Thing{}, things...)
+		if names := treeNames(buildThingTree(items, c.parents), ""); !reflect.DeepEqual(names, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, names)
+		}
+	}
+}
+
+func TestBuildThingTreeCycle(t *testing.T) {
+
+	things := []api.Thing{
+		{Id: "1", Name: "A", Type: "device"},
+		{Id: "2", Name: "B", Type: "device"},
+		{Id: "3", Name: "C", Type: "device"},
+		{Id: "4", Name: "A-Temp", Type: "sensor"},
+		{Id: "5", Name: "Garage", Type: "sensor"},
+	}
+
+	// A -> B -> C -> A, sensor of A is not part of cycle
+	parents := map[string]string{"1": "2", "2": "3", "3": "1", "4": "1"}
+
+	expected := []string{"A", "-A-Temp", "B", "C", "Garage"}
+	if names := treeNames(buildThingTree(things, parents), ""); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
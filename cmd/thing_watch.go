@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"piot-cli/api"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// move cursor to top left corner and clear screen
+	SCREEN_CLEAR = "\033[H\033[2J"
+)
+
+var (
+	config_interval time.Duration
+)
+
+// getWatchOverrides returns highlighted cells of thing which changed since
+// previous poll: changed value and flip between fresh and overdue state.
+// Cells which are not highlighted are wrapped in default color to keep the
+// same width of escape sequences in all rows (tabwriter counts them as text).
+func getWatchOverrides(thing *api.Thing, previous map[string]api.Thing) map[string]string {
+
+	overrides := map[string]string{}
+	for _, column := range watchHighlightedColumns {
+		overrides[column] = fmt.Sprintf(DefaultColor, formatThingField(thing, column))
+	}
+
+	prev, ok := previous[thing.Id]
+	if !ok {
+		return overrides
+	}
+
+	if prev.Sensor.Value != thing.Sensor.Value {
+		overrides["value"] = fmt.Sprintf(WarningColor, formatThingField(thing, "value"))
+	}
+
+	was_overdue := isThingOverdue(&prev)
+	is_overdue := isThingOverdue(thing)
+	if was_overdue != is_overdue {
+		color := GreenColor
+		if is_overdue {
+			color = RedColor
+		}
+		overrides["name"] = fmt.Sprintf(color, formatThingField(thing, "name"))
+		overrides["overdue"] = fmt.Sprintf(color, formatThingField(thing, "overdue"))
+	}
+
+	return overrides
+}
+
+// columns which cells could be highlighted in watch mode
+var watchHighlightedColumns = []string{"name", "value", "overdue"}
+
+// runThingWatch refreshes table of things until ctx is cancelled
+func runThingWatch(ctx context.Context, client api.PiotAPI, out io.Writer) error {
+
+	if config_interval <= 0 {
+		return fmt.Errorf("Invalid refresh interval: %s", config_interval)
+	}
+
+	columns, filter, err := prepareThingListing()
+	if err != nil {
+		return err
+	}
+
+	err = client.LoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(config_interval)
+	defer ticker.Stop()
+
+	headers := map[string]string{}
+	for _, column := range watchHighlightedColumns {
+		headers[column] = fmt.Sprintf(DefaultColor, thingFields[column].header)
+	}
+
+	previous := map[string]api.Thing{}
+
+	for {
+		// render whole screen to buffer first to avoid flickering
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "Every %s: piot thing watch   %s\n\n", config_interval, time.Now().Format(time.RFC1123))
+
+		things, err := getSortedThings(ctx, client, filter)
+		if ctx.Err() != nil {
+			// interrupted while waiting for server
+			fmt.Fprintln(out)
+			return nil
+		}
+		if err != nil {
+			// keep watching, server could be temporarily unavailable
+			fmt.Fprintf(&buf, ErrorColor+"\n", err)
+		} else {
+			w := tabwriter.NewWriter(&buf, 0, 0, OUTPUT_PADDING, ' ', 0)
+			printThingHeader(w, columns, headers)
+			for i := 0; i < len(things); i++ {
+				printThingRow(w, &things[i], columns, getWatchOverrides(&things[i], previous))
+			}
+			w.Flush()
+
+			previous = map[string]api.Thing{}
+			for _, thing := range things {
+				previous[thing.Id] = thing
+			}
+		}
+
+		fmt.Fprint(out, SCREEN_CLEAR)
+		buf.WriteTo(out)
+
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(out)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+var thingWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Periodically refresh list of things and highlight changes",
+	Long:  ``,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runThingWatch(cmd.Context(), newApiClient(), cmd.OutOrStdout())
+		handleError(err)
+	},
+}
+
+func init() {
+	thingCmd.AddCommand(thingWatchCmd)
+	addThingListFlags(thingWatchCmd)
+	thingWatchCmd.Flags().DurationVarP(&config_interval, "interval", "n", 5*time.Second, "refresh interval")
+}
@@ -1,16 +1,125 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"piot-cli/api"
+	"strings"
 	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	PASSWORD_CHARS  = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	PASSWORD_LENGTH = 16
 )
 
+// shared reader, answers could be piped to stdin in one buffer
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// newApiClient creates client of PIOT API used by commands, command code
+// takes api.PiotAPI and could be run against fake server (see package apitest)
+func newApiClient() api.PiotAPI {
+	return api.NewClient(log)
+}
+
 func handleError(err error) {
 	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			log.Fatal("Interrupted")
+		}
 		log.Fatal(err)
 	}
 }
 
+// askForConfirmation prints question to out and reads answer from stdin.
+// Only "y" or "yes" (case insensitive) is considered as confirmation.
+func askForConfirmation(out io.Writer, question string) bool {
+
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+// readPassword prints prompt and reads password from terminal without echo,
+// password is read as plain line if stdin is not a terminal (e.g. pipe)
+func readPassword(prompt string) (string, error) {
+
+	fmt.Print(prompt)
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := stdinReader.ReadString('\n')
+		fmt.Println()
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+
+	return string(password), nil
+}
+
+// askForNewPassword reads new password twice and checks both entries match
+func askForNewPassword() (string, error) {
+
+	password, err := readPassword("New password: ")
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", fmt.Errorf("Password cannot be empty")
+	}
+
+	again, err := readPassword("Repeat new password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != again {
+		return "", fmt.Errorf("Passwords do not match")
+	}
+
+	return password, nil
+}
+
+// generatePassword returns random password composed of characters which are
+// not easily confused (e.g. 0 and O)
+func generatePassword() (string, error) {
+
+	result := make([]byte, PASSWORD_LENGTH)
+	max := big.NewInt(int64(len(PASSWORD_CHARS)))
+
+	for i := range result {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		result[i] = PASSWORD_CHARS[n.Int64()]
+	}
+
+	return string(result), nil
+}
+
 // String returns a string representing the duration in the form "34d12h45m12s".
 // Leading zero units are omitted. Durations less than one second are ingored.
 // The zero duration formats as 0s.
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"piot-cli/api"
+	"piot-cli/apitest"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	// keep output of tests readable, logging is configured by root command
+	logging.SetLevel(logging.ERROR, LOGGER_MODULE)
+}
+
+// resetConfig sets flags shared by commands to their zero values, flags set
+// by one test must not influence other tests
+func resetConfig() {
+	config_all = false
+	config_thing_type = ""
+	config_long = false
+	config_yes = false
+	config_dry_run = false
+	config_regex = false
+	config_history = 0
+	config_tree = false
+	config_columns = ""
+	config_sort_by = ""
+	config_reverse = false
+	config_filter = ""
+
+	config_format = ""
+	config_from = ""
+	config_to = ""
+	config_names = ""
+	config_output = ""
+	config_orgs = ""
+	config_all_orgs = false
+
+	config_org_provision_influxdb = false
+
+	config_user_admin = false
+	config_user_revoke = false
+	config_user_generate_password = false
+	config_user_orgs = ""
+
+	config_check_warning = 1
+	config_check_critical = 2
+	config_check_type = ""
+	config_check_ignore = ""
+	config_check_limits = nil
+
+	config_listen = ""
+	config_poll_interval = 0
+
+	viper.Set("org", "")
+}
+
+// newTestServer starts fake PIOT server and returns client logged to it,
+// server is closed and flags are reset at the end of test
+func newTestServer(t *testing.T) (*apitest.Server, api.PiotAPI) {
+	t.Helper()
+
+	resetConfig()
+	t.Cleanup(resetConfig)
+
+	server := apitest.NewServer()
+	t.Cleanup(server.Close)
+
+	client := server.NewClient(log)
+	if err := client.LoginContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return server, client
+}
+
+// newTestInfluxServer starts fake InfluxDB server and points influxdb.url
+// configuration to it
+func newTestInfluxServer(t *testing.T) *apitest.InfluxServer {
+	t.Helper()
+
+	server := apitest.NewInfluxServer()
+	t.Cleanup(server.Close)
+
+	viper.Set("influxdb.url", server.URL)
+	t.Cleanup(func() { viper.Set("influxdb.url", "") })
+
+	return server
+}
+
+// checkGolden compares output with golden file testdata/NAME.golden, golden
+// files are rewritten if PIOT_UPDATE_GOLDEN environment variable is set
+func checkGolden(t *testing.T, name string, output []byte) {
+	t.Helper()
+
+	err := apitest.CompareGolden(filepath.Join("testdata", name+".golden"), output)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// commandCase is command run against fake server seeded by the same data as
+// other cases of table, output is compared with golden file named by case
+// (or error with expected error)
+type commandCase struct {
+	name  string
+	setup func(server *apitest.Server)
+	run   func(ctx context.Context, client api.PiotAPI, out io.Writer) error
+	err   string
+}
+
+func runCommandCases(t *testing.T, seed func(server *apitest.Server), cases []commandCase) {
+	t.Helper()
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server, client := newTestServer(t)
+			seed(server)
+			if c.setup != nil {
+				c.setup(server)
+			}
+
+			var out bytes.Buffer
+			err := c.run(context.Background(), client, &out)
+
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected error %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGolden(t, c.name, out.Bytes())
+		})
+	}
+}